@@ -2,14 +2,219 @@ package generate
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/token"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 )
 
+// maxCapacityHint is the largest maxItems which is used as the capacity of an array without a minItems, so that a
+// large bound doesn't allocate more than the arrays usually need.
+const maxCapacityHint = 64
+
 // Generator will produce structs from the JSON schema.
 type Generator struct {
+	// OptionalAsPointer makes every field which is not required a pointer, so that an absent value can be
+	// told apart from a zero value. Types which can already be nil (objects, arrays, maps and interfaces) and
+	// fields with a marshalType/unmarshalType conversion are left as they are.
+	OptionalAsPointer bool
+	// GenerateMerge emits a Merge method on every struct which copies over the fields of another instance
+	// that are not the zero value, e.g. for applying partial updates.
+	GenerateMerge bool
+	// GenerateDiff emits a Diff method on every struct which returns the values of another instance that differ,
+	// by their JSON name, e.g. for change tracking.
+	GenerateDiff bool
+	// GenerateAdditionalKeys emits an AdditionalKeys method for the structs with additional properties, which returns
+	// their keys sorted.
+	GenerateAdditionalKeys bool
+	// OrderedAdditional adds an AdditionalOrder field to the structs with additional properties, which holds the keys
+	// in the order they were unmarshalled or set, so MarshalJSON writes them in that order instead of sorted.
+	OrderedAdditional bool
+	// EnvelopeDiscriminator is the name of a property with a string const in the schemas of the structs, an
+	// UnmarshalEnvelope function is emitted which unmarshals the data of an envelope like
+	// {"type": "foo", "data": {...}} into the struct whose property has the const of its type.
+	EnvelopeDiscriminator string
+	// IncludeSchemaComments adds the $comment of the schemas, which is meant for the authors of the schema, as a
+	// "$comment:" line to the doc comments of the structs and fields.
+	IncludeSchemaComments bool
+	// MarshalOnly skips the UnmarshalJSON methods, for code which only marshals the types, so encoding/json
+	// unmarshals them without checking the constraints of the schema.
+	MarshalOnly bool
+	// MinimalMethods skips the custom MarshalJSON and UnmarshalJSON methods of structs which the struct tags
+	// alone describe, i.e. without required fields, conversions, renames or additional properties.
+	MinimalMethods bool
+	// HybridMarshal leaves the fields which encoding/json marshals like the generated code to encoding/json, only
+	// the others are marshalled by generated code.
+	HybridMarshal bool
+	// GenerateMarshalIndent emits a MarshalJSONIndent method on every struct which returns indented JSON.
+	GenerateMarshalIndent bool
+	// GenerateToMap emits a ToMap method on the structs which have custom marshal code, it is set by New.
+	GenerateToMap bool
+	// GenerateToMapFuncs adds a ToMapFuncs variable holding the ToMap method of each struct by its name, it needs
+	// GenerateToMap.
+	GenerateToMapFuncs bool
+	// EmitValidateTags adds validate struct tags for github.com/go-playground/validator derived from the
+	// required, minimum, maximum, minLength, maxLength and enum keywords. Patterns are not mapped since the
+	// validator has no built-in regular expression tag.
+	EmitValidateTags bool
+	// GenerateTextMarshalers emits MarshalText and UnmarshalText methods for string type aliases, so that they can
+	// be used as map keys and with text encoders. UnmarshalText checks the enum and pattern of the schema.
+	GenerateTextMarshalers bool
+	// GenerateEnumParsers emits a ParseX function, which returns an error for a value that isn't in the enum, and a
+	// Valid method for every string type X with an enum or a const.
+	GenerateEnumParsers bool
+	// DisallowUnknownFields makes UnmarshalJSON return an error for properties which aren't defined when the schema
+	// has "additionalProperties": false, instead of silently dropping them.
+	DisallowUnknownFields bool
+	// PreserveUnknownFields adds an Extra map[string]json.RawMessage field to structs which don't define what
+	// happens to additional properties. Unknown properties are kept in it and marshalled again verbatim.
+	PreserveUnknownFields bool
+	// AlwaysComment emits a doc comment for every field, not just the ones with a description, so that linters
+	// don't complain about undocumented exported fields.
+	AlwaysComment bool
+	// GenerateRequiredConstructor emits a NewX function for every struct X which takes the required fields as
+	// parameters, in the order of their golang names.
+	GenerateRequiredConstructor bool
+	// EmbedSchema emits a FieldNames method on every struct returning the JSON names of its properties, and a
+	// JSONSchema method returning the JSON schema the struct was generated from.
+	EmbedSchema bool
+	// StrictRequiredMarshal makes MarshalJSON return an error when a required field has the zero value, e.g. an empty
+	// string or 0, rather than only when a required object is nil.
+	StrictRequiredMarshal bool
+	// FloatSpecials is what MarshalJSON does with the NaN and infinite values of float fields, which JSON has no
+	// numbers for: "error" (or empty) returns the error of encoding/json, "null" writes null and "string" writes
+	// them as the strings "NaN", "+Inf" and "-Inf".
+	FloatSpecials string
+	// NumbersAsStrings makes the int and float64 fields of "integer" and "number" properties marshal as JSON strings
+	// like "42", and unmarshal from them, for APIs which transmit all the numbers as strings.
+	NumbersAsStrings bool
+	// DurationFormat makes string fields with "format": "duration" a time.Duration, which is written in JSON as an
+	// ISO 8601 duration like P1DT2H with "iso8601", or as a Go duration like 26h0m0s with "go". The fields stay
+	// strings when it's empty.
+	DurationFormat string
+	// UseGoogleUUID makes string fields with "format": "uuid" a github.com/google/uuid.UUID, instead of a string
+	// which is validated.
+	UseGoogleUUID bool
+	// GuardUnmarshal emits a compile time assertion that the pointer to each struct with an UnmarshalJSON method
+	// implements json.Unmarshaler, and makes UnmarshalJSON return an error for a nil receiver instead of panicking.
+	GuardUnmarshal bool
+	// GenerateRequiredFields adds a <Struct>RequiredFields variable listing the JSON names of the required properties
+	// of each struct.
+	GenerateRequiredFields bool
+	// GenerateFieldMap adds a <Struct>FieldMap variable to each struct which maps the JSON names of its properties
+	// to the names of their fields.
+	GenerateFieldMap bool
+	// GenerateRandom adds a GenerateRandom method to each struct which returns values within the minimum, maximum,
+	// multipleOf, minLength, maxLength, enum and format constraints of the schema. Patterns aren't taken into
+	// account and optional structs and the values of maps are left out.
+	GenerateRandom bool
+	// GenerateReaderInterfaces adds a Get<Field> method for each field and a <Struct>Reader interface of them to
+	// each struct.
+	GenerateReaderInterfaces bool
+	// FastUnmarshal generates UnmarshalJSON methods which read the properties with a json.Decoder in a single pass,
+	// instead of decoding them into a map of their raw values first. Structs with conversions, encoded or strict
+	// integer fields, pattern properties or conditions keep the two pass method.
+	FastUnmarshal bool
+	// GenerateDiscriminators gives the properties which are a string const, like the "kind" of the variants of a
+	// polymorphic schema, an enum type named after the property with a constant for each value. The constructors of
+	// the structs, see GenerateRequiredConstructor, set them.
+	GenerateDiscriminators bool
+	// NoAdditionalProperties treats every object schema as if it was additionalProperties: false, so no
+	// AdditionalProperties fields are generated and the properties which aren't defined are dropped.
+	NoAdditionalProperties bool
+	// Canonical makes the generated MarshalJSON methods write their JSON as the JSON Canonicalization Scheme
+	// (RFC 8785) for signing JSON does, with the keys of the defined and additional properties sorted together.
+	// json.Marshal escapes <, > and & in what MarshalJSON returns, so call MarshalJSON or use a json.Encoder with
+	// SetEscapeHTML(false) to keep the canonical form.
+	Canonical bool
+	// TypedErrors makes the generated code return a *ValidationError, which names the property and the constraint,
+	// when a value doesn't meet the constraints of the schema.
+	TypedErrors bool
+	// CollectErrors makes the generated Validate methods check all the constraints and return the ones which aren't
+	// met together as ValidationErrors, instead of returning the first.
+	CollectErrors bool
+	// CapAdditionalProperties makes UnmarshalJSON limit the number of additional properties to the maxProperties of
+	// the schema less the number of defined properties, so there's room for all the defined properties.
+	CapAdditionalProperties bool
+	// ReceiverName is the name of the receiver of the methods of the structs instead of strct, e.g. for linters
+	// which prefer a short name. It can't be a name the methods use for something else.
+	ReceiverName string
+	// OptimizeFieldAlignment orders the fields of the structs from the largest type to the smallest, instead of by
+	// name, so there's less padding between them. The generated methods write the properties sorted by name
+	// whatever the order of the fields is, but encoding/json writes the fields of plain structs in their order.
+	OptimizeFieldAlignment bool
+	// PooledBuffers makes MarshalJSON write the objects into buffers from a sync.Pool and return copies of them,
+	// which makes less garbage when a lot is marshalled. It doesn't apply to Canonical output.
+	PooledBuffers bool
+	// ToolName is the name of the tool in the "Code generated by" comment of the output instead of schema-generate,
+	// e.g. for a tool which wraps the generator. It has to fit on the line of the comment.
+	ToolName string
+	// EmitXMLTags adds xml tags with the names of the properties to the fields, so the structs can be used with
+	// encoding/xml too. Properties with "x-xml-attr": true are attributes.
+	EmitXMLTags bool
+	// EmptySliceNotNull makes MarshalJSON write an empty array rather than null for the slices of array properties
+	// which are nil.
+	EmptySliceNotNull bool
+	// CoerceSingletonArrays makes UnmarshalJSON accept a single item where the schema has an array, it's
+	// unmarshalled into a slice of one item.
+	CoerceSingletonArrays bool
+	// StrictIntegers makes UnmarshalJSON return an error for a number with a fractional part in an "integer"
+	// property, whatever the Go type of the field is.
+	StrictIntegers bool
+	// SplitReadWrite adds a <Struct>Request struct without the readOnly properties and a <Struct>Response struct
+	// without the writeOnly properties for each struct which has either. The structs of their fields are the same as
+	// the ones of the struct.
+	SplitReadWrite bool
+	// MapIncludesHidden keeps the fields which aren't marshalled, e.g. AdditionalProperties, in the maps returned by
+	// ToMap, by the name of the field.
+	MapIncludesHidden bool
+	// GenerateSQLValuer adds Scan and Value methods to each struct, so that it can be stored in a JSON database column
+	// with database/sql.
+	GenerateSQLValuer bool
+	// GenerateExamples adds a <Struct>Examples function to each struct with "examples" in its schema, which returns
+	// the examples unmarshalled into structs, or the error of the first one which can't be.
+	GenerateExamples bool
+	// GenerateBenchmarks makes OutputBenchmarks write a _bench_test.go file which benchmarks marshalling and
+	// unmarshalling the structs with examples in their schema.
+	GenerateBenchmarks bool
+	// GenerateReset adds a Reset method to each struct for reusing values, e.g. from a sync.Pool.
+	GenerateReset bool
+	// DeduplicateStructs replaces structs which have the same fields as another struct by a type alias of it.
+	DeduplicateStructs bool
+	// TypePrefix and TypeSuffix are added to the names of all generated types, e.g. to keep the types generated
+	// from several schemas in one package apart.
+	TypePrefix string
+	TypeSuffix string
+	// BuildTags are written as a //go:build constraint (and the legacy // +build lines) which requires all of them,
+	// e.g. "integration" or "linux || darwin".
+	BuildTags []string
+	// KeyCase changes the JSON names properties are marshalled with, "camel" for camelCase, "snake" for snake_case
+	// or "asis", the default, to keep them. Both the names in the schema and the changed ones are unmarshalled.
+	KeyCase string
+	// CommentWrap word wraps the description comments of structs and fields at that many columns, not counting the
+	// indentation, 0 leaves every line of a description on one line.
+	CommentWrap int
+	// ExtraTags adds struct tags with the values of schema keywords, k=keyword v=tag name, e.g. "example": "doc"
+	// adds doc:"..." with the value of the "example" keyword to the fields which have one.
+	ExtraTags map[string]string
+	// TypeMapper is consulted for the Go type of each boolean, integer, number and string schema before the built-in
+	// types, with the format of the schema, e.g. to use decimal.Decimal for numbers. It returns the type, the
+	// package to import for it, or "" for none, and false to use the built-in type. The package name is taken from
+	// the qualifier of the type, packages whose names clash with each other or the imports of the generated code
+	// are imported under an alias.
+	TypeMapper func(schemaType, format string) (goType string, typeImport string, ok bool)
+	// PostProcess is given the syntax tree of the generated code by OutputFormatted before it's printed, so it can
+	// add declarations, tags or comments to it.
+	PostProcess func(file *ast.File) error
+
 	schemas  []*Schema
 	resolver *RefResolver
 	Structs  map[string]Struct
@@ -17,27 +222,62 @@ type Generator struct {
 	// cache for reference types; k=url v=type
 	refs      map[string]string
 	anonCount int
+	// the packages the types of the TypeMapper need; k=path v=alias, "" to import it under its own name
+	typeImports map[string]string
+	// the names the packages of typeImports declare; k=path v=name
+	typeImportNames map[string]string
+	// the names of the enum types of discriminator properties, which are kept in Aliases
+	discriminators map[string]bool
 }
 
 // New creates an instance of a generator which will produce structs.
 func New(schemas ...*Schema) *Generator {
 	return &Generator{
-		schemas:  schemas,
-		resolver: NewRefResolver(schemas),
-		Structs:  make(map[string]Struct),
-		Aliases:  make(map[string]Field),
-		refs:     make(map[string]string),
+		GenerateToMap:  true,
+		schemas:        schemas,
+		resolver:       NewRefResolver(schemas),
+		Structs:        make(map[string]Struct),
+		Aliases:        make(map[string]Field),
+		refs:           make(map[string]string),
+		discriminators: make(map[string]bool),
 	}
 }
 
 // CreateTypes creates types from the JSON schemas, keyed by the golang name.
 func (g *Generator) CreateTypes() (err error) {
+	if _, err := g.getBuildConstraint(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(g.ToolName, "\r\n") {
+		return fmt.Errorf("the tool name %q has a line break", g.ToolName)
+	}
+	switch g.KeyCase {
+	case "", "asis", "camel", "snake":
+	default:
+		return fmt.Errorf("unknown key case %q, expected asis, camel or snake", g.KeyCase)
+	}
+	switch g.FloatSpecials {
+	case "", "error", "null", "string":
+	default:
+		return fmt.Errorf("unknown float specials mode %q, expected error, null or string", g.FloatSpecials)
+	}
+	switch g.DurationFormat {
+	case "", "iso8601", "go":
+	default:
+		return fmt.Errorf("unknown duration format %q, expected iso8601 or go", g.DurationFormat)
+	}
 	if err := g.resolver.Init(); err != nil {
 		return err
 	}
 
 	// extract the types
 	for _, schema := range g.schemas {
+		if schema.definitionsOnly {
+			if err := g.processDefinitions(schema); err != nil {
+				return err
+			}
+			continue
+		}
 		name := g.getSchemaName("", schema)
 		rootType, err := g.processSchema(name, schema)
 		if err != nil {
@@ -54,17 +294,180 @@ func (g *Generator) CreateTypes() (err error) {
 				OmitEmpty:     false,
 				Required:      false,
 				Description:   schema.Description,
+				Pattern:       schema.Pattern,
+				Enum:          schema.Enum,
+			}
+			if len(a.Enum) == 0 && schema.Const != nil {
+				// a const is an enum of one value
+				a.Enum = []interface{}{schema.Const}
+			}
+			if strings.HasPrefix(rootType, "[]") {
+				// the slice type checks the constraints on its items
+				a.MinItems = schema.MinItems
+				a.MaxItems = schema.MaxItems
+				if schema.Items != nil {
+					a.ItemEnum = schema.Items.Enum
+				}
 			}
 			g.Aliases[a.Name] = a
 		}
 	}
-	return
+	for name := range g.discriminators {
+		if _, ok := g.Structs[name]; ok {
+			return fmt.Errorf("the discriminator type %s clashes with the struct %s", name, name)
+		}
+	}
+	if g.DeduplicateStructs {
+		g.deduplicateStructs()
+	}
+	envelopeTypes := map[string]string{}
+	for _, name := range getOrderedStructNames(g.Structs) {
+		typ := g.Structs[name].EnvelopeType
+		if other, ok := envelopeTypes[typ]; ok && typ != "" {
+			return fmt.Errorf("the envelope type %q is the type of both %s and %s", typ, other, name)
+		}
+		envelopeTypes[typ] = name
+	}
+	return g.checkReceiverName()
+}
+
+// TypeNames returns the sorted names of the structs and aliases which the output defines, whether or not they have
+// generated methods. It's empty before CreateTypes.
+func (g *Generator) TypeNames() []string {
+	names := make([]string, 0, len(g.Structs)+len(g.Aliases))
+	for name := range g.Structs {
+		names = append(names, name)
+	}
+	for name := range g.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checks the ReceiverName can replace strct in the generated methods
+func (g *Generator) checkReceiverName() error {
+	if g.ReceiverName == "" {
+		return nil
+	}
+	if !token.IsIdentifier(g.ReceiverName) {
+		return fmt.Errorf("the receiver name %q is not a valid identifier", g.ReceiverName)
+	}
+	// the methods as they are written before the receiver is renamed
+	plain := *g
+	plain.ReceiverName = ""
+	var buf bytes.Buffer
+	emitMethods(&buf, &plain, make(map[string]bool))
+	if usesIdentifier(buf.Bytes(), g.ReceiverName) {
+		return fmt.Errorf("the receiver name %q is used by the generated methods", g.ReceiverName)
+	}
+	return nil
+}
+
+// replaces structs which have the same fields as another struct by an alias of it, the struct which comes first by
+// name is kept. References to the replaced structs are updated, so structs which only differed in those become
+// identical too and are replaced on the next pass.
+func (g *Generator) deduplicateStructs() {
+	for {
+		kept := map[string]string{}
+		replaced := map[string]string{}
+		for _, name := range getOrderedStructNames(g.Structs) {
+			key := getStructKey(g.Structs[name])
+			if original, ok := kept[key]; ok {
+				replaced[name] = original
+				continue
+			}
+			kept[key] = name
+		}
+		if len(replaced) == 0 {
+			return
+		}
+		for name, original := range replaced {
+			s := g.Structs[name]
+			delete(g.Structs, name)
+			g.Aliases[name] = Field{
+				Name:          name,
+				MarshalType:   original,
+				UnmarshalType: original,
+				Description:   s.Description,
+				Alias:         true,
+			}
+		}
+		for _, s := range g.Structs {
+			for k, f := range s.Fields {
+				f.MarshalType = replaceTypeNames(f.MarshalType, replaced)
+				f.UnmarshalType = replaceTypeNames(f.UnmarshalType, replaced)
+				s.Fields[k] = f
+			}
+			s.AdditionalType = replaceTypeNames(s.AdditionalType, replaced)
+			g.Structs[s.Name] = s
+		}
+	}
+}
+
+// returns a key which is the same for structs which generate the same code apart from their names and descriptions.
+// The key is the JSON of the struct, so the constraints are compared by their values rather than their pointers.
+func getStructKey(s Struct) string {
+	name := s.Name
+	s.ID, s.Name, s.Title, s.Description, s.Comment, s.RawSchema = "", "", "", "", "", ""
+	fields := make(map[string]Field, len(s.Fields))
+	for k, f := range s.Fields {
+		f.Description = ""
+		f.Comment = ""
+		fields[k] = f
+	}
+	s.Fields = fields
+	key, err := json.Marshal(s)
+	if err != nil {
+		// a struct which can't be compared is kept
+		return "struct " + name
+	}
+	return string(key)
+}
+
+var typeNamePattern = regexp.MustCompile(`[A-Za-z0-9_.]+`)
+
+// returns the type with the names of the types in replacements replaced, e.g. "[]*A" becomes "[]*B" for A=B
+func replaceTypeNames(typ string, replacements map[string]string) string {
+	return typeNamePattern.ReplaceAllStringFunc(typ, func(name string) string {
+		if replacement, ok := replacements[name]; ok {
+			return replacement
+		}
+		return name
+	})
+}
+
+// returns the name of the tool in the comment which marks the output as generated
+func (g *Generator) getToolName() string {
+	if g.ToolName == "" {
+		return "schema-generate"
+	}
+	return g.ToolName
+}
+
+// returns the expression which requires all the build tags, each of them is a single tag or an expression like
+// "linux || darwin". The expression is nil when there are none.
+func (g *Generator) getBuildConstraint() (constraint.Expr, error) {
+	var expr constraint.Expr
+	for _, tag := range g.BuildTags {
+		x, err := constraint.Parse("//go:build " + tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid build tag %q: %v", tag, err)
+		}
+		if expr == nil {
+			expr = x
+		} else {
+			expr = &constraint.AndExpr{X: expr, Y: x}
+		}
+	}
+	return expr, nil
 }
 
 // process a block of definitions
 func (g *Generator) processDefinitions(schema *Schema) error {
-	for key, subSchema := range schema.Definitions {
-		if _, err := g.processSchema(getGolangName(key), subSchema); err != nil {
+	// in order, so that the names of inline objects which clash are chosen deterministically
+	for _, key := range getOrderedKeys(schema.Definitions) {
+		if _, err := g.processSchema(g.getTypeName(getGolangName(key)), schema.Definitions[key]); err != nil {
 			return err
 		}
 	}
@@ -98,10 +501,28 @@ func (g *Generator) processSchema(schemaName string, schema *Schema) (typ string
 	if len(schema.Definitions) > 0 {
 		g.processDefinitions(schema)
 	}
+	if schema.Boolean != nil {
+		// the field of a false schema rejects any value when it's unmarshalled
+		return "interface{}", nil
+	}
 	schema.FixMissingTypeValue()
 	// if we have multiple schema types, the golang type will be interface{}
 	typ = "interface{}"
 	types, isMultiType := schema.MultiType()
+	// a nullable primitive, e.g. [ "integer", "null" ], is a pointer to that primitive rather than interface{}, so
+	// that integers aren't decoded as float64 values
+	if len(types) == 2 && contains(types, "null") {
+		for _, schemaType := range types {
+			switch schemaType {
+			case "boolean", "integer", "number", "string":
+				rv, err := g.getPrimitiveTypeName(schemaType, schema.Format)
+				if err != nil {
+					return "", err
+				}
+				return "*" + rv, nil
+			}
+		}
+	}
 	if len(types) > 0 {
 		for _, schemaType := range types {
 			name := schemaName
@@ -126,7 +547,7 @@ func (g *Generator) processSchema(schemaName string, schema *Schema) (typ string
 					return rv, nil
 				}
 			default:
-				rv, err := getPrimitiveTypeName(schemaType, "", false)
+				rv, err := g.getPrimitiveTypeName(schemaType, schema.Format)
 				if err != nil {
 					return "", err
 				}
@@ -143,12 +564,112 @@ func (g *Generator) processSchema(schemaName string, schema *Schema) (typ string
 	return // return interface{}
 }
 
+// returns true for an object schema without a title which hasn't been generated yet, so it's named after its property
+func isInlineObject(schema *Schema) bool {
+	typ, _ := schema.Type()
+	return schema.Title == "" && schema.Reference == "" && schema.GeneratedType == "" &&
+		(typ == "object" || (typ == "" && len(schema.Properties) > 0))
+}
+
+// returns the Go type of a schema type which isn't an object or array, from the TypeMapper if it has one
+func (g *Generator) getPrimitiveTypeName(schemaType string, format string) (string, error) {
+	if g.TypeMapper != nil {
+		if typ, typeImport, ok := g.TypeMapper(schemaType, format); ok {
+			if typeImport != "" {
+				typ = g.addTypeImport(typ, typeImport)
+			}
+			return typ, nil
+		}
+	}
+	return getPrimitiveTypeName(schemaType, "", false)
+}
+
+// the packages the generated code can import itself, a package of the TypeMapper with the same name as one of them is
+// imported under an alias
+var generatedImports = []string{"bytes", "database/sql/driver", "encoding/base64", "encoding/json", "errors", "fmt",
+	"math", "math/rand", "reflect", "regexp", "sort", "strconv", "strings", "sync", "time", "unicode/utf16"}
+
+// a major version from 2 on at the end of an import path, which isn't part of the package name
+var majorVersionSuffix = regexp.MustCompile(`/v([2-9]|[1-9][0-9]+)$`)
+
+// adds the package of a type of the TypeMapper to the imports and returns the type with its package name replaced by
+// the alias of the import if another package with the same name is imported already, e.g. "v1.Time" becomes
+// "bv1.Time" for "example.com/b/v1" when "example.com/a/v1" is imported as v1
+func (g *Generator) addTypeImport(typ string, typeImport string) string {
+	if g.typeImports == nil {
+		g.typeImports = make(map[string]string)
+		g.typeImportNames = make(map[string]string)
+	}
+	name := getImportName(typ, typeImport)
+	alias, ok := g.typeImports[typeImport]
+	if !ok {
+		taken := make(map[string]bool)
+		for _, generated := range generatedImports {
+			if generated != typeImport {
+				taken[path.Base(generated)] = true
+			}
+		}
+		for k, v := range g.typeImports {
+			if v == "" {
+				v = g.typeImportNames[k]
+			}
+			taken[v] = true
+		}
+		if taken[name] {
+			// the alias is made of the last elements of the path, with a number if that's taken too
+			elements := strings.Split(majorVersionSuffix.ReplaceAllString(typeImport, ""), "/")
+			alias = getPackageAlias(name)
+			if len(elements) > 1 {
+				alias = getPackageAlias(elements[len(elements)-2] + name)
+			}
+			for i, base := 2, alias; taken[alias]; i++ {
+				alias = fmt.Sprintf("%s%d", base, i)
+			}
+		}
+		g.typeImports[typeImport] = alias
+		g.typeImportNames[typeImport] = name
+	}
+	if alias == "" {
+		return typ
+	}
+	return typeNamePattern.ReplaceAllStringFunc(typ, func(typeName string) string {
+		if strings.HasPrefix(typeName, name+".") {
+			return alias + strings.TrimPrefix(typeName, name)
+		}
+		return typeName
+	})
+}
+
+// returns the name of the package a type of the TypeMapper is in: the qualifier of the type, e.g. "big" for
+// "*big.Int", or else the last element of the import path without its major version, e.g. "big" for
+// "example.com/big/v2"
+func getImportName(typ string, typeImport string) string {
+	for _, typeName := range typeNamePattern.FindAllString(typ, -1) {
+		if i := strings.Index(typeName, "."); i > 0 {
+			return typeName[:i]
+		}
+	}
+	return path.Base(majorVersionSuffix.ReplaceAllString(typeImport, ""))
+}
+
+// returns s in lower case without the characters which can't be in a package name, e.g. "example.com" becomes
+// "examplecom"
+func getPackageAlias(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
 // name: name of this array, usually the js key
 // schema: items element
 func (g *Generator) processArray(name string, schema *Schema) (typeStr string, err error) {
 	if schema.Items != nil {
 		// subType: fallback name in case this array contains inline object without a title
-		subName := g.getSchemaName(name+"Items", schema.Items)
+		// the items are named after the array without its prefix and suffix, getSchemaName adds them again
+		subName := g.getSchemaName(g.getUnaffixedName(name)+"Items", schema.Items)
 		subTyp, err := g.processSchema(subName, schema.Items)
 		if err != nil {
 			return "", err
@@ -183,28 +704,76 @@ func (g *Generator) processObject(name string, schema *Schema) (typ string, err
 	strct := Struct{
 		ID:          schema.ID(),
 		Name:        name,
+		Title:       schema.Title,
 		Description: schema.Description,
+		Comment:     schema.Comment,
+		Deprecated:  schema.Deprecated,
+		RawSchema:   getCompactJSON(schema.Raw),
 		Fields:      make(map[string]Field, len(schema.Properties)),
 	}
+	if schema.PropertyNames != nil && schema.PropertyNames.Pattern != "" {
+		if _, err := regexp.Compile(schema.PropertyNames.Pattern); err != nil {
+			return "", fmt.Errorf("invalid propertyNames pattern for %s: %v", name, err)
+		}
+		strct.PropertyNamesPattern = schema.PropertyNames.Pattern
+	}
 	// cache the object name in case any sub-schemas recursively reference it
 	schema.GeneratedType = "*" + name
-	// regular properties
-	for propKey, prop := range schema.Properties {
-		fieldName := getGolangName(propKey)
+	// regular properties, in order so that colliding golang names are disambiguated deterministically
+	propKeys := make([]string, 0, len(schema.Properties))
+	for propKey := range schema.Properties {
+		propKeys = append(propKeys, propKey)
+	}
+	sort.Strings(propKeys)
+	marshalNames := map[string]string{}
+	// only object schemas keep their JSON, so the keywords of the properties are looked up in this one
+	var rawProperties map[string]json.RawMessage
+	if len(g.ExtraTags) > 0 {
+		var keywords struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		}
+		if err := json.Unmarshal(schema.Raw, &keywords); err == nil {
+			rawProperties = keywords.Properties
+		}
+	}
+	for _, propKey := range propKeys {
+		prop := schema.Properties[propKey]
+		fieldName := getUniqueFieldName(getGolangName(propKey), strct.Fields)
 		// calculate sub-schema name here, may not actually be used depending on type of schema!
 		subSchemaName := g.getSchemaName(fieldName, prop)
+		if _, ok := g.Structs[subSchemaName]; ok && isInlineObject(prop) {
+			// another object already has the name, so qualify it with the name of this one
+			subSchemaName = g.getTypeName(g.getUnaffixedName(name) + fieldName)
+		}
 		fieldType, err := g.processSchema(subSchemaName, prop)
 		if err != nil {
 			return "", err
 		}
+		typeImport := ""
+		if isSchemaless(prop) {
+			// anything is valid, so keep the JSON as it is rather than decoding it into an interface{}
+			fieldType = "json.RawMessage"
+			typeImport = "encoding/json"
+		}
 
 		marshalName := propKey
 		marshalType := fieldType
 		unmarshalName := propKey
 		unmarshalType := fieldType
 
+		var unmarshalAliases []string
 		if prop.MarshalKey != "" {
 			marshalName = prop.MarshalKey
+		} else if g.KeyCase != "" && g.KeyCase != "asis" {
+			marshalName = convertKeyCase(propKey, g.KeyCase)
+			if other, ok := marshalNames[marshalName]; ok {
+				return "", fmt.Errorf("the %s key case of %q and %q of %s are both %q", g.KeyCase, other, propKey, name, marshalName)
+			}
+			marshalNames[marshalName] = propKey
+			if marshalName != propKey {
+				// accept the keys as they are marshalled too
+				unmarshalAliases = []string{marshalName}
+			}
 		}
 
 		if prop.MarshalType != "" {
@@ -220,23 +789,142 @@ func (g *Generator) processObject(name string, schema *Schema) (typ string, err
 		}
 
 		f := Field{
-			Name:          fieldName,
-			MarshalName:   marshalName,
-			UnmarshalName: unmarshalName,
-			MarshalType:   marshalType,
-			UnmarshalType: unmarshalType,
-			OmitEmpty:     prop.OmitEmpty,
-			Required:      contains(schema.Required, propKey),
-			Description:   prop.Description,
-		}
-		if f.Required {
+			Name:             fieldName,
+			MarshalName:      marshalName,
+			UnmarshalName:    unmarshalName,
+			UnmarshalAliases: unmarshalAliases,
+			MarshalType:      marshalType,
+			UnmarshalType:    unmarshalType,
+			OmitEmpty:        prop.OmitEmpty,
+			OmitNull:         prop.OmitNull,
+			Required:         contains(schema.Required, propKey),
+			Description:      prop.Description,
+			Comment:          prop.Comment,
+			Deprecated:       prop.Deprecated,
+			ReadOnly:         prop.ReadOnly,
+			WriteOnly:        prop.WriteOnly,
+			Minimum:          prop.Minimum,
+			Maximum:          prop.Maximum,
+			MinLength:        prop.MinLength,
+			MaxLength:        prop.MaxLength,
+			Pattern:          prop.Pattern,
+			Enum:             prop.Enum,
+			MultipleOf:       prop.MultipleOf,
+			Format:           prop.Format,
+			TypeImport:       typeImport,
+			XMLAttr:          prop.XMLAttr,
+		}
+		if prop.Boolean != nil && !*prop.Boolean {
+			// there is never a value to marshal
+			f.Disallowed = true
+			f.OmitEmpty = true
+		}
+		for keyword, tag := range g.ExtraTags {
+			if value, ok := getKeywordValue(rawProperties[propKey], keyword); ok {
+				if f.ExtraTags == nil {
+					f.ExtraTags = make(map[string]string)
+				}
+				f.ExtraTags[tag] = value
+			}
+		}
+		if value, ok := prop.Const.(string); ok && g.EnvelopeDiscriminator != "" && propKey == g.EnvelopeDiscriminator {
+			strct.EnvelopeType = value
+		}
+		if value, ok := prop.Const.(string); ok && g.GenerateDiscriminators && f.MarshalType == f.UnmarshalType &&
+			(f.MarshalType == "string" || f.MarshalType == "interface{}") {
+			f.MarshalType = g.addDiscriminator(propKey, value)
+			f.UnmarshalType = f.MarshalType
+			f.Discriminator = value
+		}
+		if g.UseGoogleUUID && f.Format == "uuid" && f.MarshalType == "string" && f.UnmarshalType == "string" {
+			f.MarshalType = "uuid.UUID"
+			f.UnmarshalType = f.MarshalType
+			f.TypeImport = "github.com/google/uuid"
+		}
+		if g.NumbersAsStrings && f.MarshalType == f.UnmarshalType && (f.MarshalType == "int" || f.MarshalType == "float64") {
+			// the wire type is a string, emitUnmarshalFieldCode parses the number in it
+			f.UnmarshalType = "string"
+			f.NumberAsString = true
+		}
+		if g.DurationFormat != "" && f.Format == "duration" && f.MarshalType == "string" && f.UnmarshalType == "string" {
+			// the wire type stays a string, emitUnmarshalFieldCode parses it
+			f.MarshalType = "time.Duration"
+			f.TypeImport = "time"
+			f.DurationFormat = g.DurationFormat
+		}
+		if (prop.ContentEncoding == "base64" || prop.ContentEncoding == "base64url") && f.MarshalType == "string" {
+			// the wire type stays a string, emitUnmarshalFieldCode decodes it
+			f.MarshalType = "[]byte"
+			f.ContentEncoding = prop.ContentEncoding
+		}
+		if strings.HasPrefix(f.MarshalType, "[]") && f.MarshalType == f.UnmarshalType && f.MarshalType != "[]byte" {
+			// every valid array has at least minItems items, maxItems only bounds it
+			if prop.MinItems != nil && *prop.MinItems > 0 {
+				f.Capacity = *prop.MinItems
+			} else if prop.MaxItems != nil && *prop.MaxItems > 0 && *prop.MaxItems <= maxCapacityHint {
+				f.Capacity = *prop.MaxItems
+			}
+		}
+		if prop.Not != nil {
+			notValues := prop.Not.Enum
+			if prop.Not.Const != nil {
+				notValues = append([]interface{}{prop.Not.Const}, notValues...)
+			}
+			// only keep the values the field can hold
+			if len(getTypedEnum(notValues, strings.TrimPrefix(f.MarshalType, "*"))) > 0 {
+				f.NotValues = notValues
+			}
+		}
+		if prop.Contains != nil && strings.HasPrefix(f.MarshalType, "[]") {
+			containsValues := prop.Contains.Enum
+			if prop.Contains.Const != nil {
+				containsValues = append([]interface{}{prop.Contains.Const}, containsValues...)
+			}
+			f.ContainsValues = containsValues
+			f.ContainsMinimum = prop.Contains.Minimum
+			f.ContainsMaximum = prop.Contains.Maximum
+			f.MinContains = prop.MinContains
+			f.MaxContains = prop.MaxContains
+		}
+		if typ, _ := prop.Type(); typ == "integer" && g.StrictIntegers {
+			f.StrictInteger = true
+		}
+		if prop.MultipleOf != nil && *prop.MultipleOf <= 0 {
+			return "", fmt.Errorf("multipleOf of %s.%s must be greater than 0", name, fieldName)
+		}
+		if g.OptionalAsPointer && !f.Required && f.MarshalType == f.UnmarshalType && !isNillable(f.MarshalType) {
+			f.MarshalType = "*" + f.MarshalType
+			f.UnmarshalType = f.MarshalType
+			// a nil pointer means the value was absent, so leave it out when marshalling
+			f.OmitEmpty = true
+			strct.GenerateCode = true
+		}
+		if g.CoerceSingletonArrays && strings.HasPrefix(f.MarshalType, "[]") && f.MarshalType != "[]byte" &&
+			f.MarshalType == f.UnmarshalType {
+			f.CoerceSingleton = true
+		}
+		if g.EmptySliceNotNull && strings.HasPrefix(f.MarshalType, "[]") && f.MarshalType != "[]byte" {
+			f.NilAsEmpty = true
+		}
+		if (g.FloatSpecials == "null" || g.FloatSpecials == "string") && !f.NumberAsString &&
+			(f.MarshalType == "float64" || f.MarshalType == "*float64") {
+			f.FloatSpecials = g.FloatSpecials
+		}
+		if f.Required || f.MarshalType != f.UnmarshalType || f.MarshalName != f.UnmarshalName || hasValidation(f) || f.OmitNull ||
+			f.StrictInteger || f.CoerceSingleton || f.NilAsEmpty || f.Disallowed || f.FloatSpecials != "" {
 			strct.GenerateCode = true
 		}
 		strct.Fields[f.Name] = f
 	}
+	additional := schema.AdditionalProperties
+	if g.NoAdditionalProperties {
+		// every schema is locked down as if it was additionalProperties: false
+		disallowed := false
+		additional = &AdditionalProperties{AdditionalPropertiesBool: &disallowed}
+	}
 	// additionalProperties with typed sub-schema
-	if schema.AdditionalProperties != nil && schema.AdditionalProperties.AdditionalPropertiesBool == nil {
-		ap := (*Schema)(schema.AdditionalProperties)
+	if additional != nil && additional.AdditionalPropertiesBool == nil {
+		ap := (*Schema)(additional)
 		apName := g.getSchemaName("", ap)
 		subTyp, err := g.processSchema(apName, ap)
 		if err != nil {
@@ -272,8 +960,8 @@ func (g *Generator) processObject(name string, schema *Schema) (typ string, err
 		strct.AdditionalType = subTyp
 	}
 	// additionalProperties as either true (everything) or false (nothing)
-	if schema.AdditionalProperties != nil && schema.AdditionalProperties.AdditionalPropertiesBool != nil {
-		if *schema.AdditionalProperties.AdditionalPropertiesBool == true {
+	if additional != nil && additional.AdditionalPropertiesBool != nil {
+		if *additional.AdditionalPropertiesBool == true {
 			// everything is valid additional
 			subTyp := "map[string]interface{}"
 			f := Field{
@@ -296,11 +984,323 @@ func (g *Generator) processObject(name string, schema *Schema) (typ string, err
 			strct.AdditionalType = "false"
 		}
 	}
+	if g.OrderedAdditional && strct.AdditionalType != "" && strct.AdditionalType != "false" {
+		if _, ok := strct.Fields["AdditionalOrder"]; ok {
+			return "", errors.New("processObject: the AdditionalOrder field of " + name + " collides with a property")
+		}
+		strct.Fields["AdditionalOrder"] = Field{
+			Name:          "AdditionalOrder",
+			MarshalName:   "-",
+			UnmarshalName: "-",
+			MarshalType:   "[]string",
+			UnmarshalType: "[]string",
+			Description:   "AdditionalOrder holds the keys of the additional properties in the order they were added.",
+		}
+		strct.OrderedAdditional = true
+	}
+	// patternProperties with a single pattern are kept in a map of their own
+	if len(schema.PatternProperties) == 1 {
+		for pattern, pp := range schema.PatternProperties {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return "", fmt.Errorf("invalid patternProperties pattern for %s: %v", name, err)
+			}
+			if _, ok := strct.Fields["PatternProperties"]; ok {
+				return "", errors.New("processObject: the PatternProperties field of " + name + " collides with a property")
+			}
+			subTyp, err := g.processSchema(g.getSchemaName(name+"PatternProperty", pp), pp)
+			if err != nil {
+				return "", err
+			}
+			strct.Fields["PatternProperties"] = Field{
+				Name:          "PatternProperties",
+				MarshalName:   "-",
+				UnmarshalName: "-",
+				MarshalType:   "map[string]" + subTyp,
+				UnmarshalType: "map[string]" + subTyp,
+			}
+			strct.GenerateCode = true
+			strct.PatternPropertiesPattern = pattern
+			strct.PatternPropertiesType = subTyp
+		}
+	}
+	// unknown properties are kept verbatim when the schema doesn't say what to do with them
+	if g.PreserveUnknownFields && strct.AdditionalType == "" {
+		if _, ok := strct.Fields["Extra"]; ok {
+			return "", errors.New("processObject: the Extra field for unknown properties of " + name + " collides with a property")
+		}
+		f := Field{
+			Name:          "Extra",
+			MarshalName:   "-",
+			UnmarshalName: "-",
+			MarshalType:   "map[string]json.RawMessage",
+			UnmarshalType: "map[string]json.RawMessage",
+			OmitEmpty:     false,
+			Required:      false,
+			Description:   "",
+		}
+		strct.Fields[f.Name] = f
+		strct.GenerateCode = true
+		strct.CaptureUnknown = true
+	}
+	for _, example := range schema.Examples {
+		raw, err := json.Marshal(example)
+		if err != nil {
+			return "", fmt.Errorf("invalid example for %s: %v", name, err)
+		}
+		strct.Examples = append(strct.Examples, string(raw))
+	}
+	if schema.MinProperties != nil || schema.MaxProperties != nil {
+		strct.MinProperties = schema.MinProperties
+		strct.MaxProperties = schema.MaxProperties
+		strct.GenerateCode = true
+	}
+	if len(schema.DependentRequired) > 0 {
+		strct.DependentRequired = schema.DependentRequired
+		strct.GenerateCode = true
+	}
+	if c, ok := getCondition(schema, strct.Fields); ok {
+		strct.Conditions = append(strct.Conditions, c)
+		strct.GenerateCode = true
+	}
+	if g.Canonical {
+		// encoding/json writes the fields in the order of the struct rather than sorted by key
+		strct.GenerateCode = true
+	}
+	strct.NoMarshaler = schema.NoMarshaler
+	if g.SplitReadWrite {
+		if err := g.addReadWriteStructs(strct); err != nil {
+			return "", err
+		}
+	}
 	g.Structs[strct.Name] = strct
 	// objects are always a pointer
 	return getPrimitiveTypeName("object", name, true)
 }
 
+// adds the const value of a discriminator property to the values of its enum type, named after the property, and
+// returns the name of the type
+func (g *Generator) addDiscriminator(property, value string) string {
+	name := g.getTypeName(getGolangName(property))
+	a, ok := g.Aliases[name]
+	if !ok {
+		a = Field{
+			Name:          name,
+			MarshalType:   "string",
+			UnmarshalType: "string",
+		}
+		g.discriminators[name] = true
+	}
+	if !containsValue(a.Enum, value) {
+		a.Enum = append(a.Enum, value)
+	}
+	g.Aliases[name] = a
+	return name
+}
+
+// returns true when the values contain the value
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// adds the request and response structs of a struct with readOnly or writeOnly fields
+func (g *Generator) addReadWriteStructs(s Struct) error {
+	split := false
+	for _, f := range s.Fields {
+		split = split || f.ReadOnly || f.WriteOnly
+	}
+	if !split {
+		return nil
+	}
+	request := getStructWithout(s, s.Name+"Request", func(f Field) bool { return f.ReadOnly })
+	response := getStructWithout(s, s.Name+"Response", func(f Field) bool { return f.WriteOnly })
+	for _, strct := range []Struct{request, response} {
+		if _, ok := g.Structs[strct.Name]; ok {
+			return fmt.Errorf("the readOnly and writeOnly split of %s clashes with the existing %s", s.Name, strct.Name)
+		}
+		g.Structs[strct.Name] = strct
+	}
+	return nil
+}
+
+// returns a copy of the struct with the given name which leaves out the fields that are excluded, and the conditions
+// and dependencies on their properties
+func getStructWithout(s Struct, name string, exclude func(Field) bool) Struct {
+	strct := s
+	strct.Name = name
+	// the envelopes of the type hold the struct itself
+	strct.EnvelopeType = ""
+	strct.Fields = make(map[string]Field, len(s.Fields))
+	excluded := map[string]bool{}
+	for k, f := range s.Fields {
+		if exclude(f) {
+			excluded[f.MarshalName] = true
+			continue
+		}
+		strct.Fields[k] = f
+	}
+	strct.Conditions = nil
+	for _, c := range s.Conditions {
+		keep := !excluded[c.Property]
+		for _, p := range append(append([]string{}, c.Then...), c.Else...) {
+			keep = keep && !excluded[p]
+		}
+		if keep {
+			strct.Conditions = append(strct.Conditions, c)
+		}
+	}
+	strct.DependentRequired = nil
+	for property, required := range s.DependentRequired {
+		keep := !excluded[property]
+		for _, p := range required {
+			keep = keep && !excluded[p]
+		}
+		if keep {
+			if strct.DependentRequired == nil {
+				strct.DependentRequired = map[string][]string{}
+			}
+			strct.DependentRequired[property] = required
+		}
+	}
+	return strct
+}
+
+// returns the condition of the if/then/else of the schema, only an if schema which compares a single property with a
+// string, number or boolean const is supported, e.g. {"properties": {"type": {"const": "X"}}}.
+func getCondition(schema *Schema, fields map[string]Field) (Condition, bool) {
+	if schema.If == nil || len(schema.If.Properties) != 1 || (schema.Then == nil && schema.Else == nil) {
+		return Condition{}, false
+	}
+	c := Condition{}
+	for propKey, prop := range schema.If.Properties {
+		c.Property = propKey
+		c.Value = prop.Const
+	}
+	for _, f := range fields {
+		if f.UnmarshalName == c.Property {
+			c.Field = f
+		}
+	}
+	switch c.Value.(type) {
+	case string:
+		if strings.TrimPrefix(c.Field.MarshalType, "*") != "string" {
+			return Condition{}, false
+		}
+	case float64:
+		if t := strings.TrimPrefix(c.Field.MarshalType, "*"); t != "int" && t != "float64" {
+			return Condition{}, false
+		}
+	case bool:
+		if strings.TrimPrefix(c.Field.MarshalType, "*") != "bool" {
+			return Condition{}, false
+		}
+	default:
+		return Condition{}, false
+	}
+	c.PropertyRequired = contains(schema.If.Required, c.Property)
+	if schema.Then != nil {
+		c.Then = schema.Then.Required
+	}
+	if schema.Else != nil {
+		c.Else = schema.Else.Required
+	}
+	return c, len(c.Then) > 0 || len(c.Else) > 0
+}
+
+// returns the key in the case, "camel" or "snake"
+func convertKeyCase(key string, keyCase string) string {
+	words := splitKeyWords(key)
+	switch keyCase {
+	case "camel":
+		for i, word := range words {
+			if i == 0 {
+				words[i] = strings.ToLower(word)
+			} else {
+				words[i] = capitaliseFirstLetter(strings.ToLower(word))
+			}
+		}
+		return strings.Join(words, "")
+	case "snake":
+		for i, word := range words {
+			words[i] = strings.ToLower(word)
+		}
+		return strings.Join(words, "_")
+	}
+	return key
+}
+
+// splits the key into its words at separators and changes of case, e.g. "HTTPServer_id" into HTTP, Server and id
+func splitKeyWords(key string) []string {
+	words := []string{}
+	runes := []rune(key)
+	start := 0
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == ' ' || r == '.' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i > start && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// returns name, or when a field of that name already exists, name with the first free "_<n>" suffix, e.g. both
+// "user-id" and "user_id" become "UserId", so the second one is called "UserId_2".
+func getUniqueFieldName(name string, fields map[string]Field) string {
+	if _, ok := fields[name]; !ok {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := name + "_" + strconv.Itoa(i)
+		if _, ok := fields[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
+// returns the value of the keyword in the raw JSON schema, strings as they are and anything else as JSON
+func getKeywordValue(raw []byte, keyword string) (string, bool) {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keywords); err != nil {
+		return "", false
+	}
+	value, ok := keywords[keyword]
+	if !ok {
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, true
+	}
+	return getCompactJSON(value), true
+}
+
+// returns the JSON without insignificant whitespace, or an empty string if there is none
+func getCompactJSON(raw []byte) string {
+	buf := bytes.NewBuffer([]byte{})
+	if err := json.Compact(buf, raw); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
 func contains(s []string, e string) bool {
 	for _, a := range s {
 		if a == e {
@@ -310,6 +1310,23 @@ func contains(s []string, e string) bool {
 	return false
 }
 
+// returns true when the schema has no keywords which restrict or describe the type of the values, e.g. {}
+func isSchemaless(schema *Schema) bool {
+	return schema.Boolean == nil && schema.TypeValue == nil && schema.Reference == "" && len(schema.Properties) == 0 && schema.Items == nil &&
+		schema.AdditionalProperties == nil && len(schema.AnyOf) == 0 && len(schema.AllOf) == 0 &&
+		len(schema.OneOf) == 0 && schema.Enum == nil && schema.Const == nil && schema.MarshalType == "" &&
+		schema.UnmarshalType == ""
+}
+
+// returns true when the zero value of the golang type is nil
+func isNillable(typ string) bool {
+	return strings.HasPrefix(typ, "*") ||
+		strings.HasPrefix(typ, "[]") ||
+		strings.HasPrefix(typ, "map[") ||
+		typ == "interface{}" ||
+		typ == "json.RawMessage"
+}
+
 func getPrimitiveTypeName(schemaType string, subType string, pointer bool) (name string, err error) {
 	switch schemaType {
 	case "array":
@@ -344,22 +1361,32 @@ func getPrimitiveTypeName(schemaType string, subType string, pointer bool) (name
 // return a name for this (sub-)schema.
 func (g *Generator) getSchemaName(keyName string, schema *Schema) string {
 	if len(schema.Title) > 0 {
-		return getGolangName(schema.Title)
+		return g.getTypeName(getGolangName(schema.Title))
 	}
 	if keyName != "" {
-		return getGolangName(keyName)
+		return g.getTypeName(getGolangName(keyName))
 	}
 	if schema.Parent == nil {
-		return "Root"
+		return g.getTypeName("Root")
 	}
 	if schema.JSONKey != "" {
-		return getGolangName(schema.JSONKey)
+		return g.getTypeName(getGolangName(schema.JSONKey))
 	}
 	if schema.Parent != nil && schema.Parent.JSONKey != "" {
-		return getGolangName(schema.Parent.JSONKey + "Item")
+		return g.getTypeName(getGolangName(schema.Parent.JSONKey + "Item"))
 	}
 	g.anonCount++
-	return fmt.Sprintf("Anonymous%d", g.anonCount)
+	return g.getTypeName(fmt.Sprintf("Anonymous%d", g.anonCount))
+}
+
+// returns the name with the TypePrefix and TypeSuffix of the generator
+func (g *Generator) getTypeName(name string) string {
+	return g.TypePrefix + name + g.TypeSuffix
+}
+
+// returns the name without the TypePrefix and TypeSuffix of the generator
+func (g *Generator) getUnaffixedName(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(name, g.TypePrefix), g.TypeSuffix)
 }
 
 // getGolangName strips invalid characters out of golang struct or field names.
@@ -414,12 +1441,63 @@ type Struct struct {
 	ID string
 	// The golang name, e.g. "Address"
 	Name string
+	// Title of the struct
+	Title string
 	// Description of the struct
 	Description string
-	Fields      map[string]Field
+	// Comment is the $comment of the schema
+	Comment string
+	Fields  map[string]Field
 
-	GenerateCode   bool
+	GenerateCode bool
+	// NoMarshaler leaves out the MarshalJSON and UnmarshalJSON methods, the other methods are still generated, but
+	// Validate isn't called when the struct is unmarshalled
+	NoMarshaler    bool
 	AdditionalType string
+	// PropertyNamesPattern is the regular expression the names of additional properties must match
+	PropertyNamesPattern string
+	// PatternPropertiesPattern is the pattern of the names of the properties kept in the PatternProperties field,
+	// PatternPropertiesType the type of their values
+	PatternPropertiesPattern string
+	PatternPropertiesType    string
+	// CaptureUnknown is set when properties which aren't defined are kept as raw JSON in the Extra field
+	CaptureUnknown bool
+	// OrderedAdditional is set when the keys of the additional properties are kept in order in the AdditionalOrder
+	// field
+	OrderedAdditional bool
+	// RawSchema is the compacted JSON of the schema the struct was generated from, if it was parsed from JSON
+	RawSchema string
+	// Deprecated is set when the schema of the struct is marked as deprecated
+	Deprecated bool
+	// Conditions require properties depending on the value of another property. UnmarshalJSON checks them and
+	// DependentRequired against the properties of the JSON, Validate only for the pointer and omitempty fields, as
+	// whether the others are set can't be told.
+	Conditions []Condition
+	// DependentRequired maps the JSON names of properties to the properties which are required when they are present
+	DependentRequired map[string][]string
+	// Examples are the compacted JSON of the examples in the schema
+	Examples []string
+	// MinProperties and MaxProperties limit the number of properties, including additional ones. UnmarshalJSON counts
+	// the properties of the JSON; Validate can only tell that the pointer and omitempty fields are set, so it counts
+	// those and the additional properties but not the other fields.
+	MinProperties *int
+	MaxProperties *int
+	// EnvelopeType is the const of the EnvelopeDiscriminator property, the type of the envelopes which hold the struct
+	EnvelopeType string
+}
+
+// Condition defines the properties which an if/then/else schema requires depending on the value of a property.
+type Condition struct {
+	// Property is the JSON name of the property the if schema compares, Field is its field
+	Property string
+	Field    Field
+	// Value is the const the property is compared with
+	Value interface{}
+	// PropertyRequired is set when the if schema requires the property, otherwise an absent property matches
+	PropertyRequired bool
+	// Then and Else are the properties which are required when the if schema matches or doesn't match
+	Then []string
+	Else []string
 }
 
 // Field defines the data required to generate a field in Go.
@@ -430,6 +1508,8 @@ type Field struct {
 	MarshalName string
 	// The JSON name when unmarshalling JSON, e.g. "address1"
 	UnmarshalName string
+	// UnmarshalAliases are other JSON names which are accepted when unmarshalling, e.g. the name in another KeyCase
+	UnmarshalAliases []string
 	// The golang type of the field, e.g. a built-in type like "string" or the name of a struct generated
 	// from the JSON schema.
 	MarshalType string
@@ -437,7 +1517,119 @@ type Field struct {
 	UnmarshalType string
 	// The type to cast from
 	OmitEmpty bool
+	// OmitNull leaves the field out when marshalling if it is nil instead of writing null, unlike OmitEmpty, values
+	// like empty slices are kept
+	OmitNull bool
 	// Required is set to true when the field is required.
 	Required    bool
 	Description string
+	// Comment is the $comment of the schema of the property
+	Comment string
+	// Minimum and Maximum are the inclusive bounds of a numeric value
+	Minimum *float64
+	Maximum *float64
+	// MinLength and MaxLength bound the length of a string value
+	MinLength *int
+	MaxLength *int
+	// StrictInteger is set when the schema of the field is an "integer" which is checked for a fractional part
+	StrictInteger bool
+	// Disallowed is set for the properties with the schema false, UnmarshalJSON returns an error for any value
+	Disallowed bool
+	// XMLAttr is set when the xml tag of the field makes it an attribute
+	XMLAttr bool
+	// NilAsEmpty is set when a nil slice is marshalled as an empty array instead of null
+	NilAsEmpty bool
+	// CoerceSingleton is set when a single item is unmarshalled into the slice as if it was an array of it
+	CoerceSingleton bool
+	// Capacity is the capacity an array value is made with before it's unmarshalled into, 0 for none
+	Capacity int
+	// MinItems and MaxItems bound the number of items of an array root, ItemEnum lists the values they may hold
+	MinItems *int
+	MaxItems *int
+	ItemEnum []interface{}
+	// Pattern is the regular expression a string value must match
+	Pattern string
+	// Enum lists the values the field may hold
+	Enum []interface{}
+	// NotValues lists the values the field may not hold, from the const or enum of a not schema
+	NotValues []interface{}
+	// ContainsValues, ContainsMinimum and ContainsMaximum are the const or enum and the bounds of the contains schema
+	// of an array, which between MinContains (1 if it's nil) and MaxContains of the items must be valid against
+	ContainsValues  []interface{}
+	ContainsMinimum *float64
+	ContainsMaximum *float64
+	MinContains     *int
+	MaxContains     *int
+	// MultipleOf is the number a numeric value must be a multiple of, validated by the generated code
+	MultipleOf *float64
+	// Format is the semantic format of a string value, e.g. "email"
+	Format string
+	// TypeImport is the package the golang type of the field needs to be imported from, if any
+	TypeImport string
+	// ExtraTags are additional struct tags, k=tag name v=value
+	ExtraTags map[string]string
+	// Deprecated is set when the schema of the field is marked as deprecated
+	Deprecated bool
+	// ReadOnly and WriteOnly are set when the schema of the field is marked as readOnly or writeOnly
+	ReadOnly  bool
+	WriteOnly bool
+	// ContentEncoding is the encoding of a []byte field in JSON, "base64" or "base64url"
+	ContentEncoding string
+	// DurationFormat is the format of a time.Duration field in JSON, "iso8601" or "go"
+	DurationFormat string
+	// NumberAsString is set when the number of the field is written in JSON as a string
+	NumberAsString bool
+	// FloatSpecials is how the NaN and infinite values of a float field are marshalled, "null" or "string"
+	FloatSpecials string
+	// Discriminator is the const of a discriminator property, the field has the enum type of the property and the
+	// constructor of the struct sets it
+	Discriminator string
+	// Alias is set for the aliases which are declared as type aliases, e.g. "type A = B", so they keep the methods
+	// of their type
+	Alias bool
+}
+
+// the regular expressions string values of a "format" are validated with
+var formatPatterns = map[string]string{
+	"email": `^[^@\s]+@[^@\s]+\.[^@\s]+$`,
+	"uuid":  `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+}
+
+// returns true when the string field has a format which the generated Validate method checks
+func hasFormatValidation(f Field) bool {
+	_, ok := formatPatterns[f.Format]
+	return ok && (f.MarshalType == "string" || f.MarshalType == "*string")
+}
+
+// returns the condition an item of the array field has to meet to match its contains schema, empty when there isn't
+// a contains schema the generated code can check
+func getContainsCondition(f Field, item string) string {
+	typ := strings.TrimPrefix(f.MarshalType, "[]")
+	if typ == f.MarshalType {
+		return ""
+	}
+	conditions := []string{}
+	if literals := getTypedEnum(f.ContainsValues, typ); len(literals) > 0 {
+		values := make([]string, len(literals))
+		for i, literal := range literals {
+			values[i] = item + " == " + literal
+		}
+		conditions = append(conditions, "("+strings.Join(values, " || ")+")")
+	}
+	number := item
+	if typ == "int" {
+		number = "float64(" + item + ")"
+	}
+	if f.ContainsMinimum != nil && (typ == "int" || typ == "float64") {
+		conditions = append(conditions, number+" >= "+strconv.FormatFloat(*f.ContainsMinimum, 'f', -1, 64))
+	}
+	if f.ContainsMaximum != nil && (typ == "int" || typ == "float64") {
+		conditions = append(conditions, number+" <= "+strconv.FormatFloat(*f.ContainsMaximum, 'f', -1, 64))
+	}
+	return strings.Join(conditions, " && ")
+}
+
+// returns true when the generated Validate method has to check the field
+func hasValidation(f Field) bool {
+	return f.MultipleOf != nil || hasFormatValidation(f) || len(f.NotValues) > 0 || getContainsCondition(f, "item") != ""
 }
@@ -1,7 +1,11 @@
 package generate
 
 import (
+	"bytes"
 	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -779,3 +783,334 @@ func TestTypeAliases(t *testing.T) {
 type Root struct {
 	Name interface{} `json:"name,omitempty"`
 }
+
+func TestThatReferencesToOtherLocalFilesAreResolved(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"common.json": `{
+			"definitions": {
+				"address": {
+					"type": "object",
+					"properties": {
+						"street": { "type": "string" }
+					}
+				}
+			}
+		}`,
+		"customer.json": `{
+			"title": "Customer",
+			"type": "object",
+			"properties": {
+				"home": { "$ref": "common.json#/definitions/address" },
+				"addresses": { "type": "array", "items": { "$ref": "common.json#/definitions/address" } }
+			}
+		}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the customer schema is an input, common.json is loaded because it is referenced
+	schemas, err := ReadInputFiles([]string{filepath.Join(dir, "customer.json")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(schemas...)
+	if err := g.CreateTypes(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := g.Structs["Address"]; !ok {
+		t.Fatalf("expected the referenced definition to get a struct, but only got %v", getStructNamesFromMap(g.Structs))
+	}
+	customer := g.Structs["Customer"]
+	testField(customer.Fields["Home"], "home", "Home", "*Address", false, t)
+	testField(customer.Fields["Addresses"], "addresses", "Addresses", "[]*Address", false, t)
+}
+
+func TestThatReferencesToYAMLFilesAreResolved(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"common.yaml": "definitions:\n  address:\n    type: object\n    properties:\n      street: { type: string }\n",
+		"customer.json": `{
+			"title": "Customer",
+			"type": "object",
+			"properties": {
+				"home": { "$ref": "common.yaml#/definitions/address" }
+			}
+		}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := ReadInputFiles([]string{filepath.Join(dir, "customer.json")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(schemas...)
+	if err := g.CreateTypes(); err != nil {
+		t.Fatal(err)
+	}
+
+	testField(g.Structs["Customer"].Fields["Home"], "home", "Home", "*Address", false, t)
+	testField(g.Structs["Address"].Fields["Street"], "street", "Street", "string", false, t)
+}
+
+func TestThatCollidingFieldNamesAreDisambiguated(t *testing.T) {
+	root := &Schema{
+		Title: "Root",
+		Properties: map[string]*Schema{
+			"user_id": {TypeValue: "integer"},
+			"user-id": {TypeValue: "string"},
+			"userId":  {TypeValue: "boolean"},
+		},
+	}
+	root.Init()
+
+	g := New(root)
+	if err := g.CreateTypes(); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := g.Structs["Root"].Fields
+	if len(fields) != 3 {
+		t.Fatalf("expected 3 fields, got %v", getOrderedFieldNames(fields))
+	}
+	testField(fields["UserId"], "user-id", "UserId", "string", false, t)
+	testField(fields["UserId_2"], "userId", "UserId_2", "bool", false, t)
+	testField(fields["UserId_3"], "user_id", "UserId_3", "int", false, t)
+	for _, f := range fields {
+		if f.UnmarshalName != f.MarshalName {
+			t.Errorf("expected %s to keep its JSON name for unmarshalling, got %s", f.Name, f.UnmarshalName)
+		}
+	}
+}
+
+func TestThatTypePrefixAndSuffixApplyToNestedTypes(t *testing.T) {
+	root := &Schema{
+		Title: "Root",
+		Properties: map[string]*Schema{
+			"address": {
+				TypeValue:  "object",
+				Properties: map[string]*Schema{"street": {TypeValue: "string"}},
+			},
+			"tags": {
+				TypeValue: "array",
+				Items: &Schema{
+					TypeValue:  "object",
+					Properties: map[string]*Schema{"name": {TypeValue: "string"}},
+				},
+			},
+			"code": {Reference: "#/definitions/code"},
+		},
+		Definitions: map[string]*Schema{
+			"code": {TypeValue: "string"},
+		},
+	}
+	root.Init()
+
+	g := New(root)
+	g.TypePrefix = "Api"
+	g.TypeSuffix = "V1"
+	if err := g.CreateTypes(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"ApiAddressV1", "ApiRootV1", "ApiTagsItemsV1"}
+	if actual := getOrderedStructNames(g.Structs); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the structs %v, got %v", expected, actual)
+	}
+	fields := g.Structs["ApiRootV1"].Fields
+	testField(fields["Address"], "address", "Address", "*ApiAddressV1", false, t)
+	testField(fields["Tags"], "tags", "Tags", "[]*ApiTagsItemsV1", false, t)
+	testField(fields["Code"], "code", "Code", "string", false, t)
+}
+
+func TestThatKeysAreConvertedToTheKeyCase(t *testing.T) {
+	tests := []struct {
+		key   string
+		camel string
+		snake string
+	}{
+		{key: "user_name", camel: "userName", snake: "user_name"},
+		{key: "userName", camel: "userName", snake: "user_name"},
+		{key: "HTTPServer-id", camel: "httpServerId", snake: "http_server_id"},
+		{key: "address2line", camel: "address2line", snake: "address2line"},
+		{key: "UserID", camel: "userId", snake: "user_id"},
+	}
+	for _, test := range tests {
+		if actual := convertKeyCase(test.key, "camel"); actual != test.camel {
+			t.Errorf("expected %q in camel case to be %q, got %q", test.key, test.camel, actual)
+		}
+		if actual := convertKeyCase(test.key, "snake"); actual != test.snake {
+			t.Errorf("expected %q in snake case to be %q, got %q", test.key, test.snake, actual)
+		}
+	}
+}
+
+func TestThatOpenAPIComponentsAreGenerated(t *testing.T) {
+	document := `{
+		"openapi": "3.0.3",
+		"info": { "title": "pets", "version": "1" },
+		"paths": {},
+		"components": {
+			"schemas": {
+				"Pet": {
+					"type": "object",
+					"properties": {
+						"name": { "type": "string" },
+						"owner": { "$ref": "#/components/schemas/Owner" },
+						"tags": { "type": "array", "items": { "$ref": "#/components/schemas/Tag" } }
+					},
+					"required": [ "name" ]
+				},
+				"Owner": {
+					"type": "object",
+					"properties": {
+						"id": { "type": "integer" }
+					}
+				},
+				"Tag": { "type": "string" }
+			}
+		}
+	}`
+	uri, _ := url.Parse("file:///openapi.json")
+	root, err := ParseOpenAPI(document, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(root)
+	if err := g.CreateTypes(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Owner", "Pet"}
+	if actual := getOrderedStructNames(g.Structs); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the structs %v, got %v", expected, actual)
+	}
+	fields := g.Structs["Pet"].Fields
+	testField(fields["Name"], "name", "Name", "string", true, t)
+	testField(fields["Owner"], "owner", "Owner", "*Owner", false, t)
+	testField(fields["Tags"], "tags", "Tags", "[]string", false, t)
+
+	// the document isn't a schema itself, so there's no type for it
+	if _, ok := g.Aliases["Root"]; ok {
+		t.Errorf("expected no Root type, got the aliases %v", getOrderedFieldNames(g.Aliases))
+	}
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	if strings.Contains(buf.String(), "Root") {
+		t.Errorf("expected no Root type in the output, got:\n%s", buf.String())
+	}
+
+	if _, err := ParseOpenAPI(`{ "components": {} }`, uri); err == nil {
+		t.Error("expected a document without an openapi key to be rejected")
+	}
+}
+
+func TestThatReadAndWriteModelsAreSplit(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"id": { "type": "integer", "readOnly": true },
+			"name": { "type": "string" },
+			"password": { "type": "string", "writeOnly": true }
+		},
+		"required": [ "id", "name" ]
+	}`
+	uri, _ := url.Parse("file:///test.json")
+	root, err := ParseWithSchemaKeyRequired(schema, uri, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(root)
+	g.SplitReadWrite = true
+	if err := g.CreateTypes(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"Root":         {"Id", "Name", "Password"},
+		"RootRequest":  {"Name", "Password"},
+		"RootResponse": {"Id", "Name"},
+	}
+	for name, expected := range tests {
+		s, ok := g.Structs[name]
+		if !ok {
+			t.Errorf("expected the %s struct to be generated", name)
+			continue
+		}
+		if actual := getOrderedFieldNames(s.Fields); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected %s to have the fields %v, got %v", name, expected, actual)
+		}
+	}
+}
+
+func TestThatTypeNamesListTheStructsAndAliases(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"kind": { "type": "string", "const": "person" },
+			"name": { "type": "string" },
+			"address": {
+				"type": "object",
+				"properties": { "street": { "type": "string" } }
+			}
+		},
+		"required": [ "name" ]
+	}`, func(g *Generator) {
+		g.GenerateDiscriminators = true
+	})
+
+	// the structs without generated methods are defined all the same
+	if g.Structs["Address"].GenerateCode || !g.Structs["Root"].GenerateCode {
+		t.Fatal("expected only Root to have generated methods")
+	}
+	expected := []string{"Address", "Kind", "Root"}
+	if actual := g.TypeNames(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestThatDefsAreGeneratedLikeDefinitions(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"owner": { "$ref": "#/KEYWORD/person" },
+			"pets": { "type": "array", "items": { "$ref": "#/KEYWORD/pet" } }
+		},
+		"KEYWORD": {
+			"person": {
+				"type": "object",
+				"properties": { "name": { "type": "string" } },
+				"required": [ "name" ]
+			},
+			"pet": {
+				"type": "object",
+				"properties": { "owner": { "$ref": "#/KEYWORD/person" } }
+			}
+		}
+	}`
+	defs := generateFromJSON(t, strings.ReplaceAll(schema, "KEYWORD", "$defs"), nil)
+	definitions := generateFromJSON(t, strings.ReplaceAll(schema, "KEYWORD", "definitions"), nil)
+
+	expected := []string{"Person", "Pet", "Root"}
+	if actual := defs.TypeNames(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected the types %v, got %v", expected, actual)
+	}
+	if defs.Structs["Root"].Fields["Owner"].MarshalType != "*Person" {
+		t.Errorf("expected the $ref to $defs to be resolved, got %q", defs.Structs["Root"].Fields["Owner"].MarshalType)
+	}
+	var defsCode, definitionsCode bytes.Buffer
+	Output(&defsCode, defs, "main")
+	Output(&definitionsCode, definitions, "main")
+	if defsCode.String() != definitionsCode.String() {
+		t.Errorf("expected the same code from $defs and definitions, got:\n%s\nand:\n%s", defsCode.String(),
+			definitionsCode.String())
+	}
+}
@@ -5,4 +5,5 @@ go 1.18
 require (
 	golang.org/x/lint v0.0.0-20210508222113-6edffad5e616 // indirect
 	golang.org/x/tools v0.0.0-20200130002326-2f3ba24bd6e7 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -28,6 +28,13 @@ func ReadInputFiles(inputFiles []string, schemaKeyRequired bool) ([]*Schema, err
 			Path:   abPath,
 		}
 
+		if ext := path.Ext(file); ext == ".yaml" || ext == ".yml" {
+			schemas[i], err = ParseYAML(string(b), &fileURI, schemaKeyRequired)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the input YAML schema file %s with error %v", file, err)
+			}
+			continue
+		}
 		schemas[i], err = ParseWithSchemaKeyRequired(string(b), &fileURI, schemaKeyRequired)
 		if err != nil {
 			if jsonError, ok := err.(*json.SyntaxError); ok {
@@ -23,6 +23,9 @@ type Schema struct {
 	// Title and Description state the intent of the schema.
 	Title       string
 	Description string
+	// Comment is a note for the authors of the schema.
+	// http://json-schema.org/draft-07/json-schema-core.html#rfc.section.9
+	Comment string `json:"$comment"`
 
 	// TypeValue is the schema instance type.
 	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.1.1
@@ -33,10 +36,21 @@ type Schema struct {
 	UnmarshalKey  string `json:"unmarshalKey"`
 	UnmarshalType string `json:"unmarshalType"`
 	OmitEmpty     bool   `json:"omitEmpty"`
+	OmitNull      bool   `json:"omitNull"`
+
+	// NoMarshaler leaves marshalling an object to encoding/json and its struct tags, instead of generating the
+	// MarshalJSON and UnmarshalJSON methods of it.
+	NoMarshaler bool `json:"x-go-no-marshaler"`
+	// XMLAttr makes a property an attribute rather than an element in the xml tag of its field.
+	XMLAttr bool `json:"x-xml-attr"`
 
 	// Definitions are inline re-usable schemas.
 	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.9
 	Definitions map[string]*Schema
+	// Defs are the definitions under the keyword of draft 2019-09 onwards, UnmarshalJSON moves them to Definitions, so
+	// the types are the same whichever keyword a schema uses.
+	// https://json-schema.org/draft/2020-12/json-schema-core.html#section-8.2.4
+	Defs map[string]*Schema `json:"$defs"`
 
 	// Properties, Required and AdditionalProperties describe an object's child instances.
 	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.5
@@ -49,10 +63,91 @@ type Schema struct {
 	// "additionalProperties": false
 	AdditionalPropertiesBool *bool `json:"-"`
 
+	// Boolean is set for the boolean schemas, true which any value is valid against and false which no value is.
+	Boolean *bool `json:"-"`
+
+	// MinProperties and MaxProperties limit the number of properties of an object.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.5.1
+	MinProperties *int `json:"minProperties"`
+	MaxProperties *int `json:"maxProperties"`
+
+	// PatternProperties are the schemas of the properties whose names match a pattern.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.5.5
+	PatternProperties map[string]*Schema `json:"patternProperties"`
+
+	// PropertyNames is a schema every property name of the object must be valid against, only "pattern" is
+	// supported.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.5.8
+	PropertyNames *Schema `json:"propertyNames"`
+
+	// MultipleOf, Minimum and Maximum restrict numeric values, the bounds are inclusive.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.2
+	MultipleOf *float64 `json:"multipleOf"`
+	Minimum    *float64 `json:"minimum"`
+	Maximum    *float64 `json:"maximum"`
+
+	// MinItems and MaxItems restrict the length of arrays.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.4
+	MinItems *int `json:"minItems"`
+	MaxItems *int `json:"maxItems"`
+
+	// MinLength, MaxLength and Pattern restrict string values.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.3
+	MinLength *int   `json:"minLength"`
+	MaxLength *int   `json:"maxLength"`
+	Pattern   string `json:"pattern"`
+
+	// Format is the semantic format of string values, e.g. "email" or "uuid".
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.7
+	Format string `json:"format"`
+
+	// Enum lists the values which are permitted.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.1.2
+	Enum []interface{} `json:"enum"`
+
+	// ContentEncoding and ContentMediaType describe strings which hold encoded binary data.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.8
+	ContentEncoding  string `json:"contentEncoding"`
+	ContentMediaType string `json:"contentMediaType"`
+
+	// Deprecated marks values which should no longer be used.
+	// https://json-schema.org/draft/2019-09/json-schema-validation.html#rfc.section.9.3
+	Deprecated bool `json:"deprecated"`
+
+	// ReadOnly and WriteOnly mark values which are only sent by, or only sent to, the owner of the resource.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.10.3
+	ReadOnly  bool `json:"readOnly"`
+	WriteOnly bool `json:"writeOnly"`
+
+	// Const restricts the value to a single constant.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.1.3
+	Const interface{} `json:"const"`
+
+	// DependentRequired lists the properties which are required when the property they are listed under is present.
+	// https://json-schema.org/draft/2019-09/json-schema-validation.html#rfc.section.6.5.4
+	DependentRequired map[string][]string `json:"dependentRequired"`
+
+	// If, Then and Else apply Then when the instance is valid against If and Else otherwise.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.6
+	If   *Schema `json:"if"`
+	Then *Schema `json:"then"`
+	Else *Schema `json:"else"`
+
 	AnyOf []*Schema
 	AllOf []*Schema
 	OneOf []*Schema
 
+	// Not is a schema the instance must not be valid against, only a const or enum is supported.
+	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.6.7.4
+	Not *Schema `json:"not"`
+
+	// Contains is a schema some of the items of an array must be valid against, between MinContains (1 if it's
+	// missing) and MaxContains of them. Only a const, an enum, minimum and maximum are supported.
+	// https://json-schema.org/draft/2020-12/json-schema-core.html#section-10.3.1.3
+	Contains    *Schema `json:"contains"`
+	MinContains *int    `json:"minContains"`
+	MaxContains *int    `json:"maxContains"`
+
 	// Default can be used to supply a default JSON value associated with a particular schema.
 	// http://json-schema.org/draft-07/json-schema-validation.html#rfc.section.10.2
 	Default interface{}
@@ -83,6 +178,42 @@ type Schema struct {
 
 	// calculated struct name of this object, cached here
 	GeneratedType string `json:"-"`
+
+	// set for a root which only holds definitions, e.g. the components of an OpenAPI document, so it has no type
+	definitionsOnly bool
+
+	// the JSON this schema was parsed from, only kept for object schemas as the JSON of their properties is in it
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON handles unmarshalling a Schema from JSON, keeping the raw JSON of object schemas.
+func (schema *Schema) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*schema = Schema{Boolean: &b}
+		return nil
+	}
+	// the alias type doesn't have this method, so unmarshalling into it doesn't recurse
+	type schemaWithoutMethods Schema
+	if err := json.Unmarshal(data, (*schemaWithoutMethods)(schema)); err != nil {
+		return err
+	}
+	for k, d := range schema.Defs {
+		if _, ok := schema.Definitions[k]; ok {
+			return errors.New("the definition " + k + " is in both definitions and $defs")
+		}
+		if schema.Definitions == nil {
+			schema.Definitions = make(map[string]*Schema, len(schema.Defs))
+		}
+		schema.Definitions[k] = d
+	}
+	schema.Defs = nil
+	// every schema keeping a copy of its JSON would copy the nested ones again and again
+	types, _ := schema.MultiType()
+	if contains(types, "object") || (schema.TypeValue == nil && schema.Reference == "" && len(schema.Properties) > 0) {
+		schema.Raw = append(json.RawMessage(nil), data...)
+	}
+	return nil
 }
 
 // UnmarshalJSON handles unmarshalling AdditionalProperties from JSON.
@@ -244,6 +375,11 @@ func (schema *Schema) updatePathElements() {
 		p.updatePathElements()
 	}
 
+	for k, p := range schema.PatternProperties {
+		p.PathElement = "patternProperties/" + k
+		p.updatePathElements()
+	}
+
 	if schema.AdditionalProperties != nil {
 		schema.AdditionalProperties.PathElement = "additionalProperties"
 		(*Schema)(schema.AdditionalProperties).updatePathElements()
@@ -267,6 +403,10 @@ func (schema *Schema) updateParentLinks() {
 		p.Parent = schema
 		p.updateParentLinks()
 	}
+	for _, p := range schema.PatternProperties {
+		p.Parent = schema
+		p.updateParentLinks()
+	}
 	if schema.AdditionalProperties != nil {
 		schema.AdditionalProperties.Parent = schema
 		(*Schema)(schema.AdditionalProperties).updateParentLinks()
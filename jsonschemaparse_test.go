@@ -81,6 +81,38 @@ func TestThatPropertiesCanBeParsed(t *testing.T) {
 	}
 }
 
+func TestThatOnlyObjectSchemasKeepTheirJSON(t *testing.T) {
+	s := `{
+        "$schema": "http://json-schema.org/schema#",
+        "title": "root",
+        "properties": {
+            "name": { "type": "string" },
+            "tags": { "type": "array", "items": { "type": "string" } },
+            "address": { "type": [ "object", "null" ], "properties": { "street": { "type": "string" } } }
+        }
+    }`
+	so, err := Parse(s, &url.URL{Scheme: "file", Path: "jsonschemaparse_test.go"})
+	if err != nil {
+		t.Fatal("It was not possible to unmarshal the schema:", err)
+	}
+
+	for key, object := range map[string]*Schema{"root": so, "address": so.Properties["address"]} {
+		if object.Raw == nil {
+			t.Errorf("expected the object schema %s to keep its JSON", key)
+		}
+	}
+	for key, schema := range map[string]*Schema{
+		"name":           so.Properties["name"],
+		"tags":           so.Properties["tags"],
+		"tags items":     so.Properties["tags"].Items,
+		"address street": so.Properties["address"].Properties["street"],
+	} {
+		if schema.Raw != nil {
+			t.Errorf("expected %s not to keep its JSON, got %s", key, schema.Raw)
+		}
+	}
+}
+
 func TestThatPropertiesCanHaveMultipleTypes(t *testing.T) {
 	s := `{
         "$schema": "http://json-schema.org/schema#",
@@ -165,3 +197,59 @@ func TestReturnedSchemaId(t *testing.T) {
 		}
 	}
 }
+
+func TestThatYAMLIsConvertedToJSON(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		expected string
+	}{
+		{
+			yaml:     "a: 1\nb: text # comment\nc: 'it''s'\nd: \"x\\ty\"\ne:\nf: true\ng: ~\nh: 0x1F",
+			expected: `{"a":1,"b":"text","c":"it's","d":"x\ty","e":null,"f":true,"g":null,"h":31}`,
+		},
+		{
+			yaml:     "# comment\nlist:\n  - a\n  - b: 1\n    c: 2\n  -\n    - 3\nsame:\n- x\nflow: [ a, \"b, c\", { d: 1 } ]",
+			expected: `{"list":["a",{"b":1,"c":2},[3]],"same":["x"],"flow":["a","b, c",{"d":1}]}`,
+		},
+		{
+			yaml:     "---\nliteral: |\n  line 1\n    line 2\n\nfolded: >-\n  a\n  b\n\n  c\nkept: |+\n  x\n\nurl: http://example.com/#a\n",
+			expected: `{"literal":"line 1\n  line 2\n","folded":"a b\nc","kept":"x\n\n","url":"http://example.com/#a"}`,
+		},
+		{
+			yaml:     "\"quoted key\": <a> & b\n$ref: '#/definitions/a'",
+			expected: `{"quoted key":"<a> & b","$ref":"#/definitions/a"}`,
+		},
+		{
+			yaml:     "\ufefftype: object",
+			expected: `{"type":"object"}`,
+		},
+		{
+			yaml:     "minimum: .5\nmaximum: +3\nmultipleOf: 1e3\nconst: -0.25",
+			expected: `{"minimum":0.5,"maximum":3,"multipleOf":1000,"const":-0.25}`,
+		},
+		{
+			yaml:     "%YAML 1.2\n---\ndescription: a long\n  description\nenum: [ a,\n  b ]\ntitle: \"a quoted\n  title\"",
+			expected: `{"description":"a long description","enum":["a","b"],"title":"a quoted title"}`,
+		},
+		{
+			yaml:     "definitions:\n  a: &a { type: string }\nproperties:\n  b: *a",
+			expected: `{"definitions":{"a":{"type":"string"}},"properties":{"b":{"type":"string"}}}`,
+		},
+	}
+	for _, test := range tests {
+		actual, err := yamlToJSON([]byte(test.yaml))
+		if err != nil {
+			t.Errorf("failed to convert %q: %v", test.yaml, err)
+			continue
+		}
+		if string(actual) != test.expected {
+			t.Errorf("expected %q to be converted to %s, got %s", test.yaml, test.expected, actual)
+		}
+	}
+
+	for _, invalid := range []string{"a: [ 1, 2", "a: 1\n   b: 2", "a:\n\t- b", "a: .nan"} {
+		if _, err := yamlToJSON([]byte(invalid)); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package generate
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// the prefix of references to component schemas in an OpenAPI document and of the definitions they are parsed into
+const (
+	componentsPrefix  = "#/components/schemas/"
+	definitionsPrefix = "#/definitions/"
+)
+
+// ParseOpenAPI parses the component schemas of an OpenAPI 3 document from a string. The result is a schema which
+// defines each component, so the types generated from it are the same as from a JSON schema with those definitions.
+// The schema only holds the definitions, no type is generated for it.
+func ParseOpenAPI(document string, uri *url.URL) (*Schema, error) {
+	var doc struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		return nil, err
+	}
+	if doc.OpenAPI == "" {
+		return nil, errors.New("OpenAPI document \"" + uri.String() + "\" must have an openapi key")
+	}
+
+	s := &Schema{
+		ID06:            uri.String(),
+		Definitions:     doc.Components.Schemas,
+		definitionsOnly: true,
+	}
+	for _, d := range s.Definitions {
+		d.updateComponentReferences()
+	}
+	s.Init()
+
+	return s, nil
+}
+
+// points the references to component schemas at the definitions they are parsed into
+func (schema *Schema) updateComponentReferences() {
+	if strings.HasPrefix(schema.Reference, componentsPrefix) {
+		schema.Reference = definitionsPrefix + strings.TrimPrefix(schema.Reference, componentsPrefix)
+	}
+
+	subSchemas := []*Schema{schema.Items, schema.PropertyNames, schema.If, schema.Then, schema.Else, schema.Not, schema.Contains}
+	if schema.AdditionalProperties != nil {
+		subSchemas = append(subSchemas, (*Schema)(schema.AdditionalProperties))
+	}
+	subSchemas = append(subSchemas, schema.AnyOf...)
+	subSchemas = append(subSchemas, schema.AllOf...)
+	subSchemas = append(subSchemas, schema.OneOf...)
+	for _, d := range schema.Definitions {
+		subSchemas = append(subSchemas, d)
+	}
+	for _, p := range schema.Properties {
+		subSchemas = append(subSchemas, p)
+	}
+	for _, p := range schema.PatternProperties {
+		subSchemas = append(subSchemas, p)
+	}
+	for _, s := range subSchemas {
+		if s != nil {
+			s.updateComponentReferences()
+		}
+	}
+}
@@ -2,10 +2,21 @@ package generate
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"go/build/constraint"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
 	"io"
+	"math"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 func getOrderedFieldNames(m map[string]Field) []string {
@@ -30,6 +41,64 @@ func getOrderedStructNames(m map[string]Struct) []string {
 	return keys
 }
 
+func getOrderedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// returns the names of the fields in the order they're defined in the struct, sorted by name or, to save padding
+// with OptimizeFieldAlignment, from the largest type to the smallest
+func getStructFieldNames(g *Generator, s Struct) []string {
+	names := getOrderedFieldNames(s.Fields)
+	if g.OptimizeFieldAlignment {
+		sort.SliceStable(names, func(i, j int) bool {
+			return getTypeSize(g, s.Fields[names[i]].MarshalType) > getTypeSize(g, s.Fields[names[j]].MarshalType)
+		})
+	}
+	return names
+}
+
+// returns the number of bytes a value of the golang type takes on a 64 bit platform, a guess for the types of
+// other packages
+func getTypeSize(g *Generator, typ string) int {
+	switch {
+	case strings.HasPrefix(typ, "*"), strings.HasPrefix(typ, "map["), strings.HasPrefix(typ, "chan "),
+		strings.HasPrefix(typ, "func("):
+		return 8
+	case strings.HasPrefix(typ, "[]"):
+		return 24
+	}
+	switch typ {
+	case "bool", "byte", "int8", "uint8":
+		return 1
+	case "int16", "uint16":
+		return 2
+	case "int32", "uint32", "float32", "rune":
+		return 4
+	case "int", "int64", "uint", "uint64", "uintptr", "float64", "time.Duration":
+		return 8
+	case "string", "interface{}", "any", "error", "complex128":
+		return 16
+	case "json.RawMessage", "time.Time":
+		return 24
+	}
+	if a, ok := g.Aliases[typ]; ok && a.UnmarshalType != typ {
+		return getTypeSize(g, a.UnmarshalType)
+	}
+	if s, ok := g.Structs[typ]; ok {
+		size := 0
+		for _, f := range s.Fields {
+			size += getTypeSize(g, f.MarshalType)
+		}
+		return size
+	}
+	return 8
+}
+
 // returns the stringified value to check against if possible. For structs (without pointers)
 // you can't check the zero value without using the reflect package
 func getZeroValueCheck(schemaType string) (string, bool) {
@@ -39,15 +108,20 @@ func getZeroValueCheck(schemaType string) (string, bool) {
 	if strings.HasPrefix(schemaType, "[]") {
 		return "nil", true
 	}
+	if strings.HasPrefix(schemaType, "map[") {
+		return "nil", true
+	}
 	switch schemaType {
 	case "array":
 		return "nil", true
 	case "bool":
 		return "false", true
-	case "int":
+	case "int", "time.Duration":
 		return "0", true
 	case "float64":
 		return "0", true
+	case "interface{}", "json.RawMessage":
+		return "nil", true
 	case "nil":
 		return "nil", true
 	case "string":
@@ -56,33 +130,217 @@ func getZeroValueCheck(schemaType string) (string, bool) {
 	return "", false
 }
 
-// Output generates code and writes to w.
-func Output(w io.Writer, g *Generator, pkg string) {
-	structs := g.Structs
-	aliases := g.Aliases
+// returns a condition which is true when expr, of the given type, is not the zero value. Falls back to the
+// reflect package when there is no literal zero value to compare with.
+func getNonZeroCheck(expr string, schemaType string, imports map[string]bool) string {
+	if zeroVal, ok := getZeroValueCheck(schemaType); ok {
+		return expr + " != " + zeroVal
+	}
+	imports["reflect"] = true
+	return "!reflect.ValueOf(" + expr + ").IsZero()"
+}
 
-	fmt.Fprintln(w, "// Code generated by schema-generate. DO NOT EDIT.")
-	fmt.Fprintln(w)
-	fmt.Fprintf(w, "package %v\n", cleanPackageName(pkg))
+// returns a condition which is true when expr, of the given type, is the zero value, like getNonZeroCheck
+func getZeroCheck(expr string, schemaType string, imports map[string]bool) string {
+	if zeroVal, ok := getZeroValueCheck(schemaType); ok {
+		return expr + " == " + zeroVal
+	}
+	imports["reflect"] = true
+	return "reflect.ValueOf(" + expr + ").IsZero()"
+}
+
+// validationErrorType is set in the imports by the checks which return a ValidationError, so that the type is emitted
+// even without any structs. It isn't a package and is removed before the imports are written.
+const validationErrorType = "<ValidationError>"
+
+// returns the expression of the error a failed check of a property returns, the property is empty for checks of the
+// whole object and rule is the keyword of the schema which isn't met
+func getCheckError(g *Generator, property, rule, message string, imports map[string]bool) string {
+	if g.TypedErrors {
+		imports[validationErrorType] = true
+		return fmt.Sprintf("&ValidationError{Field: %q, Rule: %q, Message: %q}", property, rule, message)
+	}
+	imports["errors"] = true
+	return fmt.Sprintf("errors.New(%q)", message)
+}
+
+// returns the expression of the error a failed check of a property returns, like getCheckError, for a property and
+// message which are only known at run time. The property is an expression and the message a format and its args.
+func getFormattedCheckError(g *Generator, property, rule, format, args string, imports map[string]bool) string {
+	imports["fmt"] = true
+	if g.TypedErrors {
+		imports[validationErrorType] = true
+		return fmt.Sprintf("&ValidationError{Field: %s, Rule: %q, Message: fmt.Sprintf(%q, %s)}", property, rule, format, args)
+	}
+	return fmt.Sprintf("fmt.Errorf(%q, %s)", format, args)
+}
+
+// returns the statement which handles a failed check in Validate: returning the error or, with CollectErrors,
+// adding it to the errors which are returned together
+func getValidateFailure(g *Generator, err string) string {
+	if g.CollectErrors {
+		return "errs = append(errs, " + err + ")"
+	}
+	return "return " + err
+}
 
+// returns the check that the values of two expressions of the type differ
+func getDifferenceCheck(expr string, other string, schemaType string, imports map[string]bool) string {
+	switch schemaType {
+	case "bool", "int", "float64", "string":
+		return expr + " != " + other
+	}
+	// pointers, slices and maps are compared by what they hold
+	imports["reflect"] = true
+	return "!reflect.DeepEqual(" + expr + ", " + other + ")"
+}
+
+// Output generates code and writes to w.
+func Output(w io.Writer, g *Generator, pkg string) {
 	// write all the code into a buffer, compiler functions will return list of imports
 	// write list of imports into main output stream, followed by the code
 	codeBuf := new(bytes.Buffer)
 	imports := make(map[string]bool)
+	emitMethods(codeBuf, g, imports)
 
-	for _, k := range getOrderedStructNames(structs) {
-		s := structs[k]
-		if s.GenerateCode {
-			emitMarshalCode(codeBuf, s, imports)
-			emitUnmarshalCode(codeBuf, s, imports)
-			emitToMapCode(codeBuf, s)
+	outputTypes(w, g, pkg, imports)
+
+	// write code after structs for clarity
+	w.Write(codeBuf.Bytes())
+}
+
+// OutputFormatted generates the same code as Output and writes it formatted with gofmt, after the PostProcess hook
+// of the generator, if there is one, has transformed the syntax tree of it.
+func OutputFormatted(w io.Writer, g *Generator, pkg string) error {
+	var buf bytes.Buffer
+	Output(&buf, g, pkg)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	if g.PostProcess != nil {
+		if err := g.PostProcess(file); err != nil {
+			return err
+		}
+	}
+	return format.Node(w, fset, file)
+}
+
+// StreamOutput generates the same code as Output, but writes the methods straight to w instead of holding them in
+// memory, which helps with very large schemas. The methods are generated twice as the first pass is needed to find
+// the imports.
+func StreamOutput(w io.Writer, g *Generator, pkg string) {
+	imports := make(map[string]bool)
+	emitMethods(io.Discard, g, imports)
+
+	outputTypes(w, g, pkg, imports)
+
+	emitMethods(w, g, make(map[string]bool))
+}
+
+// OutputBenchmarks writes the _bench_test.go file of GenerateBenchmarks, with a BenchmarkXMarshalJSON and a
+// BenchmarkXUnmarshalJSON for each struct X with examples in its schema, which use the first example as the payload.
+// It writes nothing when GenerateBenchmarks isn't set.
+func OutputBenchmarks(w io.Writer, g *Generator, pkg string) {
+	if !g.GenerateBenchmarks {
+		return
+	}
+	outputHeader(w, g, pkg)
+
+	names := []string{}
+	for _, k := range getOrderedStructNames(g.Structs) {
+		if len(g.Structs[k].Examples) > 0 {
+			names = append(names, k)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	fmt.Fprintf(w, `
+import (
+	"encoding/json"
+	"testing"
+)
+`)
+	for _, name := range names {
+		fmt.Fprintf(w, `
+// the example of %[1]s in the schema which the benchmarks of %[1]s use as the payload
+var benchmark%[1]sPayload = []byte(%[2]q)
+
+func Benchmark%[1]sMarshalJSON(b *testing.B) {
+	var v %[1]s
+	if err := json.Unmarshal(benchmark%[1]sPayload, &v); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark%[1]sUnmarshalJSON(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v %[1]s
+		if err := json.Unmarshal(benchmark%[1]sPayload, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+`, name, g.Structs[name].Examples[0])
+	}
+}
+
+// writes the build constraints, the generated code notice and the package clause
+func outputHeader(w io.Writer, g *Generator, pkg string) {
+	// build constraints have to come before the package clause, followed by a blank line, CreateTypes already
+	// reported invalid ones
+	if expr, err := g.getBuildConstraint(); err == nil && expr != nil {
+		fmt.Fprintf(w, "//go:build %s\n", expr)
+		if lines, err := constraint.PlusBuildLines(expr); err == nil {
+			for _, line := range lines {
+				fmt.Fprintln(w, line)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	// the line has to match ^// Code generated .* DO NOT EDIT\.$ for tools to recognise the file as generated
+	fmt.Fprintf(w, "// Code generated by %s. DO NOT EDIT.\n", g.getToolName())
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "package %v\n", cleanPackageName(pkg))
+}
+
+// writes the header, the imports and the definitions of the aliases and structs
+func outputTypes(w io.Writer, g *Generator, pkg string, imports map[string]bool) {
+	structs := g.Structs
+	aliases := g.Aliases
+
+	outputHeader(w, g, pkg)
+
+	// the types of the fields can need imports too
+	for typeImport := range g.typeImports {
+		imports[typeImport] = true
+	}
+	for _, s := range structs {
+		for _, f := range s.Fields {
+			if f.TypeImport != "" {
+				imports[f.TypeImport] = true
+			}
 		}
 	}
 
 	if len(imports) > 0 {
 		fmt.Fprintf(w, "\nimport (\n")
-		for k := range imports {
-			fmt.Fprintf(w, "    \"%s\"\n", k)
+		for _, k := range getOrderedKeys(imports) {
+			if alias := g.typeImports[k]; alias != "" {
+				fmt.Fprintf(w, "\t%s \"%s\"\n", alias, k)
+				continue
+			}
+			fmt.Fprintf(w, "\t\"%s\"\n", k)
 		}
 		fmt.Fprintf(w, ")\n")
 	}
@@ -91,6 +349,11 @@ func Output(w io.Writer, g *Generator, pkg string) {
 		a := aliases[k]
 
 		fmt.Fprintln(w, "")
+		if a.Alias {
+			outputComment(a.Name+" has the same fields as "+a.UnmarshalType+".", "", w)
+			fmt.Fprintf(w, "type %s = %s\n", a.Name, a.UnmarshalType)
+			continue
+		}
 		fmt.Fprintf(w, "// %s\n", a.Name)
 		fmt.Fprintf(w, "type %s %s\n", a.Name, a.UnmarshalType)
 	}
@@ -99,292 +362,2719 @@ func Output(w io.Writer, g *Generator, pkg string) {
 		s := structs[k]
 
 		fmt.Fprintln(w, "")
-		outputNameAndDescriptionComment(s.Name, s.Description, w)
+		outputNameAndDescriptionComment(s.Name, s.Title, s.Description, g.CommentWrap, w)
+		if s.Deprecated {
+			outputComment("\nDeprecated: "+s.Name+" is marked as deprecated in the schema.", "", w)
+		}
+		if g.IncludeSchemaComments && s.Comment != "" {
+			outputComment(wrapComment("\n$comment: "+s.Comment, g.CommentWrap), "", w)
+		}
 		fmt.Fprintf(w, "type %s struct {\n", s.Name)
 
-		for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		for _, fieldKey := range getStructFieldNames(g, s) {
 			f := s.Fields[fieldKey]
 
-			// Only apply omitempty if the field is not required.
-			// omitempty := ",omitempty"
-			// if f.Required {
-			// 	omitempty = ""
-			// }
-
+			commented := true
 			if f.Description != "" {
-				outputFieldDescriptionComment(f.Description, w)
-			}
-
-			// fmt.Fprintf(w, "  %s %s `json:\"%s%s\"`\n", f.Name, f.UnmarshalType, f.UnmarshalName, omitempty)
-			fmt.Fprintf(w, "  %s %s\n", f.Name, f.MarshalType)
-		}
-
-		fmt.Fprintln(w, "}")
-	}
-
-	// write code after structs for clarity
-	w.Write(codeBuf.Bytes())
-}
-
-func emitMarshalCode(w io.Writer, s Struct, imports map[string]bool) {
-	fmt.Fprintf(w,
-		`
-func (strct %s) MarshalJSON() ([]byte, error) {
-	lines := []string{}
-	
-`, s.Name)
-
-	if len(s.Fields) > 0 {
-		// Marshal all the defined fields
-		for _, fieldKey := range getOrderedFieldNames(s.Fields) {
-			f := s.Fields[fieldKey]
-			if f.MarshalName == "-" {
-				continue
+				outputFieldDescriptionComment(f.Name, f.Description, g.CommentWrap, w)
+			} else if g.AlwaysComment {
+				outputFieldDescriptionComment(f.Name, getDefaultFieldDescription(f), g.CommentWrap, w)
+			} else {
+				commented = false
 			}
-			if f.Required {
-				fmt.Fprintf(w, "    // \"%s\" field is required\n", f.Name)
-				// currently only objects are supported
-				if strings.HasPrefix(f.MarshalType, "*") {
-					imports["errors"] = true
-					fmt.Fprintf(w, `    if strct.%s == nil {
-        return nil, errors.New("%s is a required field")
-    }
-`, f.Name, f.MarshalName)
+			if f.Deprecated {
+				// the Deprecated: paragraph is recognised by editors and linters
+				deprecation := fmt.Sprintf("Deprecated: the %q property is marked as deprecated in the schema.", f.MarshalName)
+				if commented {
+					deprecation = "\n" + deprecation
 				} else {
-					fmt.Fprintf(w, "    // only required object types supported for marshal checking (for now)\n")
+					fmt.Fprintln(w)
 				}
+				outputComment(deprecation, "\t", w)
+				commented = true
 			}
-
-			if f.OmitEmpty {
-				zeroVal, haveZeroVal := getZeroValueCheck(f.MarshalType)
-				if haveZeroVal {
-					fmt.Fprintf(w,
-						`	// omit empty
-	if strct.%s != %s {
-`, f.Name, zeroVal)
+			if g.IncludeSchemaComments && f.Comment != "" {
+				comment := "$comment: " + f.Comment
+				if commented {
+					comment = "\n" + comment
 				} else {
-					fmt.Fprintf(w,
-						`	// Check using reflect.Value
-	if reflect.ValueOf(strct.%s).IsZero() {
-`, f.Name)
+					fmt.Fprintln(w)
 				}
+				outputComment(wrapComment(comment, g.CommentWrap), "\t", w)
 			}
 
-			fmt.Fprintf(w,
-				`  // Marshal the "%[1]s" field
-	if tmp, err := json.Marshal(strct.%[2]s); err != nil {
-		return nil, err
-	} else {
-`, f.MarshalName, f.Name)
-			imports["fmt"] = true
-			fmt.Fprintf(w, `lines = append(lines, fmt.Sprintf("\"%[1]s\": %%s", tmp))`, f.MarshalName)
+			fmt.Fprintf(w, "\t%s %s %s\n", f.Name, f.MarshalType, getFieldTags(g, f))
+		}
 
-			if f.OmitEmpty {
-				fmt.Fprintf(w, `
+		fmt.Fprintln(w, "}")
 	}
 }
 
-`)
-			} else {
-				fmt.Fprintf(w, `
-				}
+// writes the methods of the structs and aliases, adding the packages they use to imports
+func emitMethods(w io.Writer, g *Generator, imports map[string]bool) {
+	structs := g.Structs
+	aliases := g.Aliases
 
-`)
-			}
+	for _, k := range getOrderedStructNames(structs) {
+		s := structs[k]
+		if g.ReceiverName == "" {
+			emitStructMethods(w, g, s, imports)
+			continue
 		}
+		// the methods are written with strct as the receiver, which is renamed afterwards
+		var buf bytes.Buffer
+		emitStructMethods(&buf, g, s, imports)
+		w.Write(renameIdentifier(buf.Bytes(), "strct", g.ReceiverName))
 	}
-	if s.AdditionalType != "" {
-		if s.AdditionalType != "false" {
-			imports["fmt"] = true
 
-			fmt.Fprintf(w, "    // Marshal any additional Properties\n")
-			// Marshal any additional Properties
-			fmt.Fprintf(w, `    for k, v := range strct.AdditionalProperties {`)
-			fmt.Fprintf(w, `
-			if tmp, err := json.Marshal(v); err != nil {
-				return nil, err
-			} else {
-				lines = append(lines, fmt.Sprintf("\"%%s\": %%s", k, tmp))
-			}
+	if g.GenerateToMap && g.GenerateToMapFuncs {
+		emitToMapFuncsCode(w, g)
 	}
-`)
-		}
+
+	emitFormatPatterns(w, g, imports)
+
+	if g.CollectErrors && len(structs) > 0 {
+		imports["strings"] = true
+		fmt.Fprintf(w, `
+// ValidationErrors holds all the constraints of the schema a value doesn't meet.
+type ValidationErrors []error
+
+// Error returns the messages of the errors separated by semicolons.
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
 	}
+	return strings.Join(messages, "; ")
+}
 
-	imports["strings"] = true
-	fmt.Fprintf(w, `
-	return []byte("{" + strings.Join(lines, ", ") + "}"), nil
+// Unwrap returns the errors, so errors.Is and errors.As check each of them.
+func (e ValidationErrors) Unwrap() []error {
+	return e
 }
 `)
-}
+	}
 
-func emitUnmarshalFieldCode(w io.Writer, f Field, imports map[string]bool) {
-	if f.MarshalType == f.UnmarshalType {
-		fmt.Fprintf(w, `        case "%s":
-            if err := json.Unmarshal([]byte(v), &strct.%s); err != nil {
-                return err
-             }
-`, f.UnmarshalName, f.Name)
+	emitEnvelopeCode(w, g, imports)
 
-		return
+	if usesOrderedAdditional(g) && !g.MarshalOnly {
+		emitObjectKeysCode(w, imports)
 	}
 
-	switch f.UnmarshalType {
-	case "string":
-		switch f.MarshalType {
-		case "int":
-			fmt.Fprintf(w, `        case "%s":
-            if newVal, err := strconv.ParseInt(v, 10, 0); err != nil {
-                return err
-             }
-            if err := json.Unmarshal([]byte(newVal), &strct.%s); err != nil {
-                return err
-             }
-`, f.UnmarshalName, f.Name)
-
-			return
-		default:
-			return
-		}
-	case "int":
-		switch f.MarshalType {
-		case "string":
-			imports["strconv"] = true
-			fmt.Fprintf(w, `        case "%s":
-			var intVal int
-            if err := json.Unmarshal([]byte(v), &intVal); err != nil {
-                return err
-             }
-            strct.%s = strconv.Itoa(intVal)
-`, f.UnmarshalName, f.Name)
+	if g.Canonical && len(structs) > 0 {
+		emitCanonicalJSONCode(w, imports)
+	}
 
-			return
-		default:
-			return
-		}
-	default:
-		return
+	if usesISODurations(g) {
+		emitISODurationCode(w, imports)
 	}
-}
 
-func emitUnmarshalCode(w io.Writer, s Struct, imports map[string]bool) {
-	imports["encoding/json"] = true
-	// unmarshal code
-	fmt.Fprintf(w, `
-func (strct *%s) UnmarshalJSON(b []byte) error {
-`, s.Name)
-	// setup required bools
-	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
-		f := s.Fields[fieldKey]
-		if f.Required {
-			fmt.Fprintf(w, "    %sReceived := false\n", f.UnmarshalName)
-		}
+	if g.PooledBuffers && !g.Canonical && len(structs) > 0 {
+		imports["bytes"] = true
+		imports["sync"] = true
+		fmt.Fprintf(w, `
+// marshalBuffers holds the buffers the MarshalJSON methods write the objects into.
+var marshalBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+`)
 	}
-	// setup initial unmarshal
-	fmt.Fprintf(w, `    var jsonMap map[string]json.RawMessage
-    if err := json.Unmarshal(b, &jsonMap); err != nil {
-        return err
-    }`)
 
-	// start the loop
-	fmt.Fprintf(w, `
-    // parse all the defined properties
-    for k, v := range jsonMap {
-        if v != nil {
-			switch k {
+	if g.GenerateRandom && len(structs) > 0 {
+		fmt.Fprintf(w, `
+// randomString returns a string of n random lower case letters.
+func randomString(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + r.Intn(26))
+	}
+	return string(b)
+}
 `)
-	// handle defined properties
-	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
-		f := s.Fields[fieldKey]
+	}
 
-		if f.UnmarshalName == "-" {
-			continue
+	for _, k := range getOrderedFieldNames(aliases) {
+		if g.discriminators[k] {
+			emitDiscriminatorConstants(w, aliases[k])
 		}
+	}
 
-		emitUnmarshalFieldCode(w, f, imports)
-
-		if f.Required {
-			fmt.Fprintf(w, "            %sReceived = true\n", f.UnmarshalName)
+	for _, k := range getOrderedFieldNames(aliases) {
+		if a := aliases[k]; hasItemValidation(a) {
+			emitItemValidationCode(w, g, a, imports)
 		}
 	}
 
-	// handle additional property
-	if s.AdditionalType != "" {
-		if s.AdditionalType == "false" {
-			// all unknown properties are not allowed
-			imports["fmt"] = true
-			fmt.Fprintf(w, `        default:
-            continue
-`)
-		} else {
-			fmt.Fprintf(w, `        default:
-            // an additional "%s" value
-            var additionalValue %s
-            if err := json.Unmarshal([]byte(v), &additionalValue); err != nil {
-                return err // invalid additionalProperty
-            }
-            if strct.AdditionalProperties == nil {
-                strct.AdditionalProperties = make(map[string]%s, 0)
-            }
-            strct.AdditionalProperties[k]= additionalValue
-`, s.AdditionalType, s.AdditionalType, s.AdditionalType)
-		}
-	}
-	fmt.Fprintf(w, "        }}\n") // switch
-	fmt.Fprintf(w, "    }\n")      // for
-
-	// check all Required fields were received
-	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
-		f := s.Fields[fieldKey]
-		if f.Required {
-			imports["errors"] = true
-			fmt.Fprintf(w, `    // check if %s (a required property) was received
-    if !%sReceived {
-        return errors.New("\"%s\" is required but was not present")
-    }
-`, f.UnmarshalName, f.UnmarshalName, f.UnmarshalName)
+	if g.GenerateEnumParsers {
+		for _, k := range getOrderedFieldNames(aliases) {
+			if a := aliases[k]; !a.Alias && a.UnmarshalType == "string" && len(getTypedEnum(a.Enum, "string")) > 0 {
+				emitEnumParserCode(w, a, imports)
+			}
 		}
 	}
 
-	fmt.Fprintf(w, "    return nil\n")
-	fmt.Fprintf(w, "}\n") // UnmarshalJSON
-}
-
-func emitToMapCode(w io.Writer, s Struct) {
-	// ToMap code
-	fmt.Fprintf(w, `
-func (strct *%s) ToMap() map[string]any {
-`, s.Name)
-
-	fmt.Fprintf(w, "    m := make(map[string]any)\n")
-
-	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
-		f := s.Fields[fieldKey]
-		fmt.Fprintf(w, "    m[\"%s\"] = strct.%s\n", f.MarshalName, f.Name)
+	if g.GenerateTextMarshalers {
+		for _, k := range getOrderedFieldNames(aliases) {
+			if a := aliases[k]; a.UnmarshalType == "string" {
+				emitTextMarshalCode(w, a, imports)
+			}
+		}
 	}
 
-	fmt.Fprintf(w, "    return m\n")
-	fmt.Fprintf(w, "}\n") // ToMap
+	// after all the checks, which note whether they use the type
+	if g.TypedErrors && (len(structs) > 0 || imports[validationErrorType]) {
+		fmt.Fprintf(w, `
+// ValidationError is returned when a value doesn't meet the constraints of the schema.
+type ValidationError struct {
+	// Field is the JSON name of the property, empty for constraints of a whole object
+	Field string
+	// Rule is the keyword of the constraint in the schema, e.g. "required"
+	Rule    string
+	Message string
 }
 
-func outputNameAndDescriptionComment(name, description string, w io.Writer) {
-	if strings.Index(description, "\n") == -1 {
-		fmt.Fprintf(w, "// %s %s\n", name, description)
-		return
+// Error returns the message of the error.
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+`)
 	}
-
-	dl := strings.Split(description, "\n")
-	fmt.Fprintf(w, "// %s %s\n", name, strings.Join(dl, "\n// "))
+	delete(imports, validationErrorType)
 }
 
-func outputFieldDescriptionComment(description string, w io.Writer) {
-	if strings.Index(description, "\n") == -1 {
-		fmt.Fprintf(w, "\n  // %s\n", description)
-		return
+// writes the methods of the struct, adding the packages they use to imports
+func emitStructMethods(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	if s.GenerateCode {
+		// plain structs are handled by the struct tags alone
+		if !s.NoMarshaler && (!g.MinimalMethods || g.Canonical || !isPlainStruct(s)) {
+			emitMarshalCode(w, g, s, imports)
+			if !g.MarshalOnly {
+				emitUnmarshalCode(w, g, s, imports)
+			}
+		}
+		if hasStructValidation(s) {
+			emitValidateCode(w, g, s, imports)
+		}
+		if g.GenerateToMap {
+			emitToMapCode(w, g, s, imports)
+		}
+		if s.AdditionalType != "" && s.AdditionalType != "false" {
+			emitAdditionalAccessorsCode(w, s)
+			if g.GenerateAdditionalKeys {
+				emitAdditionalKeysCode(w, s, imports)
+			}
+		}
 	}
-
-	dl := strings.Split(description, "\n")
-	fmt.Fprintf(w, "\n  // %s\n", strings.Join(dl, "\n  // "))
+	if g.GenerateRequiredConstructor || hasDiscriminator(s) {
+		emitRequiredConstructorCode(w, s)
+	}
+	if g.GenerateMerge {
+		emitMergeCode(w, s, imports)
+	}
+	if g.GenerateDiff {
+		emitDiffCode(w, s, imports)
+	}
+	if g.GenerateMarshalIndent {
+		emitMarshalIndentCode(w, s, imports)
+	}
+	if g.GenerateSQLValuer {
+		emitSQLValuerCode(w, s, imports)
+	}
+	if g.GenerateExamples && len(s.Examples) > 0 {
+		emitExamplesCode(w, s, imports)
+	}
+	if g.GenerateReset {
+		emitResetCode(w, s)
+	}
+	if g.GenerateRequiredFields {
+		emitRequiredFieldsCode(w, s)
+	}
+	if g.GenerateFieldMap {
+		emitFieldMapCode(w, s)
+	}
+	if g.GenerateRandom {
+		emitRandomCode(w, g, s, imports)
+	}
+	if g.GenerateReaderInterfaces {
+		emitReaderInterfaceCode(w, s)
+	}
+	if g.EmbedSchema {
+		emitSchemaCode(w, s)
+	}
+}
+
+// returns the code with the identifier, and the word in the comments, renamed
+func renameIdentifier(code []byte, from, to string) []byte {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(code))
+	var sc scanner.Scanner
+	sc.Init(file, code, nil, scanner.ScanComments)
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(from) + `\b`)
+	var out bytes.Buffer
+	last := 0
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if (tok == token.IDENT && lit == from) || tok == token.COMMENT {
+			offset := file.Offset(pos)
+			out.Write(code[last:offset])
+			out.WriteString(word.ReplaceAllString(lit, to))
+			last = offset + len(lit)
+		}
+	}
+	out.Write(code[last:])
+	return out.Bytes()
+}
+
+// returns true when the identifier is used in the code
+func usesIdentifier(code []byte, name string) bool {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(code))
+	var sc scanner.Scanner
+	sc.Init(file, code, nil, 0)
+	for {
+		_, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			return false
+		}
+		if tok == token.IDENT && lit == name {
+			return true
+		}
+	}
+}
+
+// indents each non-empty line of the code by another tab
+func indentCode(code string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "\t" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// returns the struct tags of the field, these are what encoding/json uses when no custom marshal code is emitted
+func getFieldTags(g *Generator, f Field) string {
+	omitempty := ""
+	if f.OmitEmpty {
+		omitempty = ",omitempty"
+	}
+	if f.UnmarshalName == "-" {
+		omitempty = ""
+	}
+	tags := fmt.Sprintf("json:\"%s%s\"", f.UnmarshalName, omitempty)
+	if g.EmitXMLTags {
+		attr := ""
+		if f.XMLAttr && f.UnmarshalName != "-" {
+			attr = ",attr"
+		}
+		tags += fmt.Sprintf(" xml:\"%s%s%s\"", f.UnmarshalName, attr, omitempty)
+	}
+	if g.EmitValidateTags {
+		if rules := getValidateRules(f); len(rules) > 0 {
+			tags += fmt.Sprintf(" validate:\"%s\"", strings.Join(rules, ","))
+		}
+	}
+	for _, name := range getOrderedKeys(f.ExtraTags) {
+		// a back quote would end the raw string literal of the tags
+		tags += " " + name + ":" + strings.Replace(strconv.Quote(f.ExtraTags[name]), "`", "\\x60", -1)
+	}
+	return "`" + tags + "`"
+}
+
+// returns the github.com/go-playground/validator rules for the constraints of the field
+func getValidateRules(f Field) []string {
+	rules := []string{}
+	if f.Minimum != nil {
+		rules = append(rules, "min="+strconv.FormatFloat(*f.Minimum, 'f', -1, 64))
+	}
+	if f.Maximum != nil {
+		rules = append(rules, "max="+strconv.FormatFloat(*f.Maximum, 'f', -1, 64))
+	}
+	if f.MinLength != nil {
+		rules = append(rules, "min="+strconv.Itoa(*f.MinLength))
+	}
+	if f.MaxLength != nil {
+		rules = append(rules, "max="+strconv.Itoa(*f.MaxLength))
+	}
+	if len(f.Enum) > 0 {
+		values := make([]string, len(f.Enum))
+		for i, e := range f.Enum {
+			values[i] = fmt.Sprint(e)
+			if strings.Contains(values[i], " ") {
+				values[i] = "'" + values[i] + "'"
+			}
+		}
+		rules = append(rules, "oneof="+strings.Join(values, " "))
+	}
+	if f.Required {
+		return append([]string{"required"}, rules...)
+	}
+	if len(rules) > 0 {
+		// the constraints only apply to values which are present
+		return append([]string{"omitempty"}, rules...)
+	}
+	return rules
+}
+
+// returns true when the struct tags alone make encoding/json behave the same as the custom marshal code would, i.e.
+// there are no required fields, conversions, renames or additional properties.
+func isPlainStruct(s Struct) bool {
+	if s.AdditionalType != "" || s.CaptureUnknown || s.PatternPropertiesPattern != "" || hasStructValidation(s) ||
+		len(s.Conditions) > 0 || len(s.DependentRequired) > 0 {
+		return false
+	}
+	for _, f := range s.Fields {
+		if f.Required || f.MarshalType != f.UnmarshalType || f.MarshalName != f.UnmarshalName || f.OmitNull ||
+			f.StrictInteger || f.CoerceSingleton || f.NilAsEmpty || f.Disallowed || f.FloatSpecials != "" {
+			return false
+		}
+		// encoding/json can only omit the values which have a literal zero value
+		if _, ok := getZeroValueCheck(f.MarshalType); f.OmitEmpty && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func emitMarshalCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	if len(s.Fields) == 0 {
+		// there's nothing to marshal, so always return an empty object
+		fmt.Fprintf(w, `
+func (strct %s) MarshalJSON() ([]byte, error) {
+	return []byte("{}"), nil
+}
+`, s.Name)
+		return
+	}
+	imports["encoding/json"] = true
+	fmt.Fprintf(w,
+		`
+func (strct %s) MarshalJSON() ([]byte, error) {
+	lines := []string{}
+
+`, s.Name)
+	hybrid := g.HybridMarshal && emitPlainFieldsMarshalCode(w, s, imports)
+
+	if len(s.Fields) > 0 {
+		// Marshal all the defined fields
+		for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+			f := s.Fields[fieldKey]
+			if f.MarshalName == "-" {
+				continue
+			}
+			if f.Required {
+				fmt.Fprintf(w, "\t// \"%s\" field is required\n", f.Name)
+				if g.StrictRequiredMarshal {
+					// a required field with the zero value wasn't set
+					fmt.Fprintf(w, `	if %s {
+		return nil, %s
+	}
+`, getZeroCheck("strct."+f.Name, f.MarshalType, imports), getCheckError(g, f.MarshalName, "required",
+						fmt.Sprintf("%q is a required field", f.MarshalName), imports))
+				} else if strings.HasPrefix(f.MarshalType, "*") {
+					// currently only objects are supported
+					// the error names the property as it is in the JSON being marshalled
+					fmt.Fprintf(w, `	if strct.%s == nil {
+		return nil, %s
+	}
+`, f.Name, getCheckError(g, f.MarshalName, "required", fmt.Sprintf("%q is a required field", f.MarshalName), imports))
+				} else {
+					fmt.Fprintf(w, "\t// only required object types supported for marshal checking (for now)\n")
+				}
+			}
+
+			if hybrid && isPlainField(f) {
+				continue
+			}
+
+			marshal := fmt.Sprintf(`	// Marshal the "%[1]s" field
+	if tmp, err := json.Marshal(%[2]s); err != nil {
+		return nil, err
+	} else {
+		lines = append(lines, "\"%[1]s\": "+string(tmp))
+	}
+`, f.MarshalName, getEncodedValue(f, imports))
+			if f.NilAsEmpty {
+				marshal = fmt.Sprintf(`	// Marshal the "%[1]s" field, a nil slice is written as an empty array
+	if strct.%[2]s == nil {
+		lines = append(lines, "\"%[1]s\": []")
+	} else if tmp, err := json.Marshal(strct.%[2]s); err != nil {
+		return nil, err
+	} else {
+		lines = append(lines, "\"%[1]s\": "+string(tmp))
+	}
+`, f.MarshalName, f.Name)
+			}
+			if f.FloatSpecials != "" {
+				marshal = getFloatSpecialsMarshalCode(f, imports)
+			}
+			if f.MarshalType == "json.RawMessage" {
+				marshal = fmt.Sprintf(`	// Marshal the "%[1]s" field, the raw JSON is written as it is
+	if len(strct.%[2]s) == 0 {
+		lines = append(lines, "\"%[1]s\": null")
+	} else {
+		lines = append(lines, "\"%[1]s\": "+string(strct.%[2]s))
+	}
+`, f.MarshalName, f.Name)
+			}
+
+			if f.OmitEmpty {
+				fmt.Fprintf(w, "\t// omit empty\n\tif %s {\n%s\t}\n\n", getNonZeroCheck("strct."+f.Name, f.MarshalType, imports), indentCode(marshal))
+			} else if f.OmitNull && isNillable(f.MarshalType) {
+				fmt.Fprintf(w, "\t// omit null\n\tif strct.%s != nil {\n%s\t}\n\n", f.Name, indentCode(marshal))
+			} else {
+				fmt.Fprintf(w, "%s\n", marshal)
+			}
+		}
+	}
+	if s.AdditionalType != "" {
+		if s.AdditionalType != "false" {
+			imports["fmt"] = true
+			imports["sort"] = true
+
+			if s.OrderedAdditional {
+				fmt.Fprintf(w, `	// Marshal any additional Properties in the order they were added, then the ones which were set directly
+	// in the map, sorted for a stable output
+	additionalKeys := make([]string, 0, len(strct.AdditionalProperties))
+	ordered := make(map[string]bool, len(strct.AdditionalProperties))
+	for _, k := range strct.AdditionalOrder {
+		if _, ok := strct.AdditionalProperties[k]; ok && !ordered[k] {
+			ordered[k] = true
+			additionalKeys = append(additionalKeys, k)
+		}
+	}
+	unordered := []string{}
+	for k := range strct.AdditionalProperties {
+		if !ordered[k] {
+			unordered = append(unordered, k)
+		}
+	}
+	sort.Strings(unordered)
+	additionalKeys = append(additionalKeys, unordered...)
+`)
+			} else {
+				fmt.Fprintf(w, "\t// Marshal any additional Properties, sorted for a stable output\n")
+				fmt.Fprintf(w, `	additionalKeys := make([]string, 0, len(strct.AdditionalProperties))
+	for k := range strct.AdditionalProperties {
+		additionalKeys = append(additionalKeys, k)
+	}
+	sort.Strings(additionalKeys)
+`)
+			}
+			fmt.Fprintf(w, `	for _, k := range additionalKeys {
+		if tmp, err := json.Marshal(strct.AdditionalProperties[k]); err != nil {
+			return nil, err
+		} else {
+			lines = append(lines, fmt.Sprintf("\"%%s\": %%s", k, tmp))
+		}
+	}
+`)
+		}
+	}
+
+	if s.PatternPropertiesPattern != "" {
+		imports["fmt"] = true
+		imports["sort"] = true
+		fmt.Fprintf(w, `	// Marshal the pattern properties, sorted for a stable output
+	patternKeys := make([]string, 0, len(strct.PatternProperties))
+	for k := range strct.PatternProperties {
+		patternKeys = append(patternKeys, k)
+	}
+	sort.Strings(patternKeys)
+	for _, k := range patternKeys {
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		if tmp, err := json.Marshal(strct.PatternProperties[k]); err != nil {
+			return nil, err
+		} else {
+			lines = append(lines, string(key)+": "+string(tmp))
+		}
+	}
+`)
+	}
+
+	if s.CaptureUnknown {
+		imports["encoding/json"] = true
+		imports["sort"] = true
+		fmt.Fprintf(w, `	// Marshal the unknown properties verbatim, sorted for a stable output
+	extraKeys := make([]string, 0, len(strct.Extra))
+	for k := range strct.Extra {
+		extraKeys = append(extraKeys, k)
+	}
+	sort.Strings(extraKeys)
+	for _, k := range extraKeys {
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, string(key)+": "+string(strct.Extra[k]))
+	}
+`)
+	}
+
+	if g.Canonical {
+		imports["strings"] = true
+		fmt.Fprintf(w, `
+	// sort the defined and the other properties together by key and write them as RFC 8785 does
+	return canonicalJSON([]byte("{" + strings.Join(lines, ",") + "}"))
+}
+`)
+		return
+	}
+	if g.PooledBuffers {
+		imports["bytes"] = true
+		fmt.Fprintf(w, `
+	// write the object into a buffer of the pool and return a copy of it, as the buffer is reused
+	buf := marshalBuffers.Get().(*bytes.Buffer)
+	defer marshalBuffers.Put(buf)
+	buf.Reset()
+	buf.WriteByte('{')
+	for i, line := range lines {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(line)
+	}
+	buf.WriteByte('}')
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+`)
+		return
+	}
+	imports["strings"] = true
+	fmt.Fprintf(w, `
+	return []byte("{" + strings.Join(lines, ", ") + "}"), nil
+}
+`)
+}
+
+// returns the code which marshals the float field, writing NaN and the infinities, which encoding/json can't
+// marshal, as null or strings
+func getFloatSpecialsMarshalCode(f Field, imports map[string]bool) string {
+	imports["math"] = true
+	value := "strct." + f.Name
+	check := fmt.Sprintf("math.IsNaN(%[1]s) || math.IsInf(%[1]s, 0)", value)
+	if strings.HasPrefix(f.MarshalType, "*") {
+		value = "*" + value
+		check = fmt.Sprintf("strct.%[1]s != nil && (math.IsNaN(%[2]s) || math.IsInf(%[2]s, 0))", f.Name, value)
+	}
+	line := fmt.Sprintf(`"\"%s\": null"`, f.MarshalName)
+	description := "null"
+	if f.FloatSpecials == "string" {
+		imports["strconv"] = true
+		// NaN, +Inf or -Inf
+		line = fmt.Sprintf(`"\"%s\": \"" + strconv.FormatFloat(%s, 'g', -1, 64) + "\""`, f.MarshalName, value)
+		description = "strings"
+	}
+	return fmt.Sprintf(`	// Marshal the "%[1]s" field, NaN and the infinities are written as %[2]s
+	if %[3]s {
+		lines = append(lines, %[4]s)
+	} else if tmp, err := json.Marshal(strct.%[5]s); err != nil {
+		return nil, err
+	} else {
+		lines = append(lines, "\"%[1]s\": "+string(tmp))
+	}
+`, f.MarshalName, description, check, line, f.Name)
+}
+
+// returns true when encoding/json marshals the field like the generated code does, with the name and omitempty
+// of the struct tag
+func isPlainField(f Field) bool {
+	if f.MarshalName != f.UnmarshalName || f.MarshalType != f.UnmarshalType || f.OmitNull || f.NilAsEmpty ||
+		f.FloatSpecials != "" {
+		return false
+	}
+	if !f.OmitEmpty {
+		return true
+	}
+	// encoding/json omits empty slices and maps too, the generated code only nil ones
+	_, ok := getZeroValueCheck(f.MarshalType)
+	return ok && !strings.HasPrefix(f.MarshalType, "[]") && !strings.HasPrefix(f.MarshalType, "map[") &&
+		f.MarshalType != "json.RawMessage"
+}
+
+// writes the code which marshals the plain fields of the struct with encoding/json, returns false if there are none
+func emitPlainFieldsMarshalCode(w io.Writer, s Struct, imports map[string]bool) bool {
+	hidden := []string{}
+	plain := false
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		if f.MarshalName == "-" {
+			continue
+		}
+		if isPlainField(f) {
+			plain = true
+			continue
+		}
+		// a nil field with the same JSON name at a shallower depth hides the field of the alias
+		hidden = append(hidden, fmt.Sprintf("\t\tHide%s *struct{} `json:\"%s,omitempty\"`\n", f.Name, f.UnmarshalName))
+	}
+	if !plain {
+		return false
+	}
+
+	imports["encoding/json"] = true
+	fmt.Fprintf(w, `	// the alias has the fields but not the methods of %[1]s, so encoding/json marshals the plain fields of it
+	type alias %[1]s
+	plain, err := json.Marshal(struct {
+		alias
+%[2]s	}{alias: alias(strct)})
+	if err != nil {
+		return nil, err
+	}
+	if len(plain) > len("{}") {
+		lines = append(lines, string(plain[1:len(plain)-1]))
+	}
+
+`, s.Name, strings.Join(hidden, ""))
+	return true
+}
+
+func emitUnmarshalFieldCode(w io.Writer, g *Generator, f Field, imports map[string]bool) {
+	labels := strconv.Quote(f.UnmarshalName)
+	for _, alias := range f.UnmarshalAliases {
+		labels += ", " + strconv.Quote(alias)
+	}
+	if f.Disallowed {
+		message := fmt.Sprintf("%q is not allowed", f.UnmarshalName)
+		fmt.Fprintf(w, `		case %s:
+			// the schema of the property is false, so no value is valid
+			return %s
+`, labels, getCheckError(g, f.UnmarshalName, "false", message, imports))
+
+		return
+	}
+	if f.MarshalType == "json.RawMessage" {
+		fmt.Fprintf(w, `		case %s:
+			// keep the raw JSON as it is
+			strct.%s = v
+`, labels, f.Name)
+
+		return
+	}
+	// the errors say which property couldn't be unmarshalled
+	imports["fmt"] = true
+	if f.MarshalType == f.UnmarshalType {
+		fmt.Fprintf(w, "\t\tcase %s:\n", labels)
+		emitStrictIntegerCheck(w, g, f, imports)
+		if f.CoerceSingleton {
+			fmt.Fprintf(w, `			// a single item is unmarshalled as if it was an array of it
+			if len(v) > 0 && v[0] != '[' && string(v) != "null" {
+				v = append(append(json.RawMessage{'['}, v...), ']')
+			}
+`)
+		}
+		if f.Capacity > 0 {
+			// encoding/json appends to the slice it's given
+			fmt.Fprintf(w, `			if cap(strct.%[1]s) < %[2]d {
+				strct.%[1]s = make(%[3]s, 0, %[2]d)
+			}
+`, f.Name, f.Capacity, f.MarshalType)
+		}
+		fmt.Fprintf(w, `			if err := json.Unmarshal([]byte(v), &strct.%s); err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+`, f.Name)
+
+		return
+	}
+
+	switch f.UnmarshalType {
+	case "string":
+		switch f.MarshalType {
+		case "[]byte":
+			if f.ContentEncoding == "" {
+				return
+			}
+			imports["encoding/base64"] = true
+			decode := "base64.StdEncoding.DecodeString(encoded)"
+			if f.ContentEncoding == "base64url" {
+				// accept the encoding with and without padding
+				imports["strings"] = true
+				decode = `base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))`
+			}
+			fmt.Fprintf(w, `		case %s:
+			var encoded string
+			if err := json.Unmarshal([]byte(v), &encoded); err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			decoded, err := %s
+			if err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			strct.%s = decoded
+`, labels, decode, f.Name)
+
+			return
+		case "time.Duration":
+			imports["time"] = true
+			parse := "time.ParseDuration(duration)"
+			if f.DurationFormat == "iso8601" {
+				parse = "parseISODuration(duration)"
+			}
+			fmt.Fprintf(w, `		case %s:
+			var duration string
+			if err := json.Unmarshal([]byte(v), &duration); err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			d, err := %s
+			if err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			strct.%s = d
+`, labels, parse, f.Name)
+
+			return
+		case "int":
+			imports["strconv"] = true
+			// the number is in a JSON string
+			fmt.Fprintf(w, `		case %s:
+			var number string
+			if err := json.Unmarshal([]byte(v), &number); err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			intVal, err := strconv.Atoi(number)
+			if err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			strct.%s = intVal
+`, labels, f.Name)
+
+			return
+		case "float64":
+			imports["strconv"] = true
+			// the number is in a JSON string
+			fmt.Fprintf(w, `		case %s:
+			var number string
+			if err := json.Unmarshal([]byte(v), &number); err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			floatVal, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			strct.%s = floatVal
+`, labels, f.Name)
+
+			return
+		default:
+			return
+		}
+	case "int":
+		switch f.MarshalType {
+		case "string":
+			imports["strconv"] = true
+			fmt.Fprintf(w, "\t\tcase %s:\n", labels)
+			emitStrictIntegerCheck(w, g, f, imports)
+			fmt.Fprintf(w, `			var intVal int
+			if err := json.Unmarshal([]byte(v), &intVal); err != nil {
+				return fmt.Errorf("field %%q: %%w", k, err)
+			}
+			strct.%s = strconv.Itoa(intVal)
+`, f.Name)
+
+			return
+		default:
+			return
+		}
+	default:
+		return
+	}
+}
+
+// checks the number has no fractional part before it's unmarshalled into the field, whatever the type of it is
+func emitStrictIntegerCheck(w io.Writer, g *Generator, f Field, imports map[string]bool) {
+	if !f.StrictInteger {
+		return
+	}
+	imports["math"] = true
+	imports["strconv"] = true
+	fmt.Fprintf(w, `			if number, err := strconv.ParseFloat(string(v), 64); err == nil && number != math.Trunc(number) {
+				return %s
+			}
+`, getFormattedCheckError(g, "k", "type", "%q must be an integer, got %s", "k, v", imports))
+}
+
+func emitUnmarshalCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	imports["encoding/json"] = true
+	checkPropertyNames := s.PropertyNamesPattern != "" && s.AdditionalType != "" && s.AdditionalType != "false"
+	if checkPropertyNames {
+		imports["regexp"] = true
+		fmt.Fprintf(w, `
+// the pattern the names of additional properties of %s must match
+var propertyNames%s = regexp.MustCompile(%q)
+`, s.Name, s.Name, s.PropertyNamesPattern)
+	}
+	if s.PatternPropertiesPattern != "" {
+		imports["regexp"] = true
+		fmt.Fprintf(w, `
+// the pattern the names of the pattern properties of %s match
+var patternProperties%s = regexp.MustCompile(%q)
+`, s.Name, s.Name, s.PatternPropertiesPattern)
+	}
+	if g.GuardUnmarshal {
+		fmt.Fprintf(w, `
+// UnmarshalJSON has a pointer receiver, so only a *%[1]s can be unmarshalled into
+var _ json.Unmarshaler = (*%[1]s)(nil)
+`, s.Name)
+	}
+	// unmarshal code
+	fmt.Fprintf(w, `
+func (strct *%s) UnmarshalJSON(b []byte) error {
+`, s.Name)
+	if g.GuardUnmarshal {
+		imports["errors"] = true
+		fmt.Fprintf(w, `	if strct == nil {
+		return errors.New("%s.UnmarshalJSON: cannot unmarshal into a nil pointer")
+	}
+`, s.Name)
+	}
+	// setup required bools
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		if f.Required {
+			fmt.Fprintf(w, "\treceived%s := false\n", f.Name)
+		}
+	}
+	if g.FastUnmarshal && canFastUnmarshal(s) {
+		emitFastUnmarshalLoopCode(w, g, s, imports)
+	} else {
+		// setup initial unmarshal
+		fmt.Fprintf(w, `	var jsonMap map[string]json.RawMessage
+	if err := json.Unmarshal(b, &jsonMap); err != nil {
+		return err
+	}`)
+
+		// an empty struct only has to check that the JSON is an object
+		parse := len(s.Fields) > 0 || (s.AdditionalType == "false" && g.DisallowUnknownFields)
+		if parse {
+			emitUnmarshalLoopCode(w, g, s, imports)
+		} else {
+			fmt.Fprintln(w)
+		}
+		if s.OrderedAdditional {
+			fmt.Fprintf(w, `	// jsonMap has no order, so the keys of the additional properties are added in the order of the JSON
+	additionalKeys, err := getObjectKeys(b)
+	if err != nil {
+		return err
+	}
+	ordered := make(map[string]bool, len(strct.AdditionalOrder))
+	for _, k := range strct.AdditionalOrder {
+		ordered[k] = true
+	}
+	for _, k := range additionalKeys {
+		if _, ok := strct.AdditionalProperties[k]; ok && !ordered[k] {
+			ordered[k] = true
+			strct.AdditionalOrder = append(strct.AdditionalOrder, k)
+		}
+	}
+`)
+		}
+	}
+	if g.CapAdditionalProperties {
+		emitAdditionalPropertiesCapCode(w, g, s, imports)
+	}
+
+	// check all Required fields were received
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		if f.Required {
+			// the error names the property as it is in the JSON being unmarshalled
+			message := fmt.Sprintf("%q is required but was not present", f.UnmarshalName)
+			fmt.Fprintf(w, `	// check if %s (a required property) was received
+	if !received%s {
+		return %s
+	}
+`, f.UnmarshalName, f.Name, getCheckError(g, f.UnmarshalName, "required", message, imports))
+		}
+	}
+
+	for _, c := range s.Conditions {
+		emitConditionCode(w, g, c, imports)
+	}
+	emitDependentRequiredCode(w, g, s, imports)
+
+	if s.MinProperties != nil || s.MaxProperties != nil {
+		emitPropertyCountCheck(w, g, s, true, imports)
+	}
+	if hasPresenceValidation(s) {
+		// the properties were counted and looked up in the JSON, which tells more than the fields
+		fmt.Fprintf(w, "\treturn strct.validate(false)\n")
+	} else if hasStructValidation(s) {
+		fmt.Fprintf(w, "\treturn strct.Validate()\n")
+	} else {
+		fmt.Fprintf(w, "\treturn nil\n")
+	}
+	fmt.Fprintf(w, "}\n") // UnmarshalJSON
+}
+
+// makes the map of the additional properties, when the schema bounds the number of properties its capacity is the
+// number of properties less the defined ones, which can't be more than the additional ones, capped by maxProperties
+func emitAdditionalPropertiesMakeCode(w io.Writer, s Struct) {
+	if s.MinProperties == nil && s.MaxProperties == nil {
+		fmt.Fprintf(w, "\t\t\t\tstrct.AdditionalProperties = make(map[string]%s, 0)\n", s.AdditionalType)
+		return
+	}
+	defined := 0
+	for _, f := range s.Fields {
+		if f.UnmarshalName != "-" {
+			defined++
+		}
+	}
+	fmt.Fprintf(w, "\t\t\t\t// at least the properties which can't be defined ones are additional, no more than maxProperties\n")
+	fmt.Fprintf(w, "\t\t\t\tsize := len(jsonMap) - %d\n", defined)
+	if s.MaxProperties != nil {
+		fmt.Fprintf(w, `				if size > %[1]d {
+					size = %[1]d
+				}
+`, *s.MaxProperties)
+	}
+	if defined > 0 {
+		fmt.Fprintf(w, `				if size < 0 {
+					size = 0
+				}
+`)
+	}
+	fmt.Fprintf(w, "\t\t\t\tstrct.AdditionalProperties = make(map[string]%s, size)\n", s.AdditionalType)
+}
+
+// checks the number of additional properties against what maxProperties leaves for them, which is only known when
+// there are no pattern or unknown properties
+func emitAdditionalPropertiesCapCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	if s.MaxProperties == nil || s.AdditionalType == "" || s.AdditionalType == "false" ||
+		s.PatternPropertiesPattern != "" || s.CaptureUnknown {
+		return
+	}
+	defined := 0
+	for _, f := range s.Fields {
+		if f.MarshalName != "-" {
+			defined++
+		}
+	}
+	limit := *s.MaxProperties - defined
+	if limit < 0 {
+		limit = 0
+	}
+	message := fmt.Sprintf("%s must have at most %d additional properties", s.Name, limit)
+	fmt.Fprintf(w, `	// maxProperties %d less the %d defined properties, whether they are present or not, is the number of
+	// additional properties there can be
+	if len(strct.AdditionalProperties) > %d {
+		return %s
+	}
+`, *s.MaxProperties, defined, limit, getCheckError(g, "", "maxProperties", message, imports))
+}
+
+// returns the error of an additional property which doesn't match the propertyNames pattern of the struct
+func getPropertyNamesError(g *Generator, s Struct, imports map[string]bool) string {
+	return getFormattedCheckError(g, "k", "propertyNames", "additional property %q does not match the pattern %q",
+		"k, propertyNames"+s.Name+".String()", imports)
+}
+
+// returns true when the properties of the struct can be decoded straight from the JSON, i.e. there's no conversion,
+// decoding or check which needs their raw values and no pattern properties, conditions, dependencies or property
+// counts
+func canFastUnmarshal(s Struct) bool {
+	if len(s.Fields) == 0 || s.PatternPropertiesPattern != "" || len(s.Conditions) > 0 ||
+		len(s.DependentRequired) > 0 || s.MinProperties != nil || s.MaxProperties != nil {
+		return false
+	}
+	for _, f := range s.Fields {
+		if f.UnmarshalName != "-" && (f.MarshalType != f.UnmarshalType || f.StrictInteger || f.CoerceSingleton || f.Disallowed) {
+			return false
+		}
+	}
+	return true
+}
+
+// writes the code which reads the properties with a json.Decoder in a single pass, instead of decoding them into a
+// map of their raw values and then decoding each of those
+func emitFastUnmarshalLoopCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	imports["bytes"] = true
+	imports["errors"] = true
+	checkPropertyNames := s.PropertyNamesPattern != "" && s.AdditionalType != "" && s.AdditionalType != "false"
+	fmt.Fprintf(w, `	dec := json.NewDecoder(bytes.NewReader(b))
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	// null leaves the struct as it is
+	if t != nil {
+		if t != json.Delim('{') {
+			return errors.New(%q)
+		}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			switch k := t.(string); k {
+`, s.Name+" must be a JSON object")
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		if f.UnmarshalName == "-" {
+			continue
+		}
+		labels := strconv.Quote(f.UnmarshalName)
+		for _, alias := range f.UnmarshalAliases {
+			labels += ", " + strconv.Quote(alias)
+		}
+		imports["fmt"] = true
+		fmt.Fprintf(w, "\t\t\tcase %s:\n", labels)
+		if f.Capacity > 0 {
+			fmt.Fprintf(w, `				if cap(strct.%[1]s) < %[2]d {
+					strct.%[1]s = make(%[3]s, 0, %[2]d)
+				}
+`, f.Name, f.Capacity, f.MarshalType)
+		}
+		fmt.Fprintf(w, `				if err := dec.Decode(&strct.%s); err != nil {
+					return fmt.Errorf("field %%q: %%w", k, err)
+				}
+`, f.Name)
+		if f.Required {
+			fmt.Fprintf(w, "\t\t\t\treceived%s = true\n", f.Name)
+		}
+	}
+
+	fmt.Fprintf(w, "\t\t\tdefault:\n")
+	switch {
+	case s.AdditionalType == "false" && g.DisallowUnknownFields:
+		unknown := getFormattedCheckError(g, "k", "additionalProperties", "unknown field %q", "k", imports)
+		fmt.Fprintf(w, "\t\t\t\treturn %s\n", unknown)
+	case s.AdditionalType != "" && s.AdditionalType != "false":
+		if checkPropertyNames {
+			fmt.Fprintf(w, `				if !propertyNames%s.MatchString(k) {
+					return %s
+				}
+`, s.Name, getPropertyNamesError(g, s, imports))
+		}
+		fmt.Fprintf(w, `				// an additional "%[1]s" value
+				var additionalValue %[1]s
+				if err := dec.Decode(&additionalValue); err != nil {
+					return err // invalid additionalProperty
+				}
+				if strct.AdditionalProperties == nil {
+					strct.AdditionalProperties = make(map[string]%[1]s)
+				}
+`, s.AdditionalType)
+		if s.OrderedAdditional {
+			fmt.Fprintf(w, `				if _, ok := strct.AdditionalProperties[k]; !ok {
+					strct.AdditionalOrder = append(strct.AdditionalOrder, k)
+				}
+`)
+		}
+		fmt.Fprintf(w, "\t\t\t\tstrct.AdditionalProperties[k] = additionalValue\n")
+	case s.CaptureUnknown:
+		fmt.Fprintf(w, `				// keep the unknown property as it is
+				var v json.RawMessage
+				if err := dec.Decode(&v); err != nil {
+					return err
+				}
+				if strct.Extra == nil {
+					strct.Extra = make(map[string]json.RawMessage)
+				}
+				strct.Extra[k] = v
+`)
+	default:
+		fmt.Fprintf(w, `				// skip the value of the unknown property
+				var v json.RawMessage
+				if err := dec.Decode(&v); err != nil {
+					return err
+				}
+`)
+	}
+	fmt.Fprintf(w, "\t\t\t}\n") // switch
+	fmt.Fprintf(w, "\t\t}\n")   // for
+	fmt.Fprintf(w, "\t}\n")     // if
+}
+
+func emitUnmarshalLoopCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	checkPropertyNames := s.PropertyNamesPattern != "" && s.AdditionalType != "" && s.AdditionalType != "false"
+	fmt.Fprintf(w, `
+	// parse all the defined properties
+	for k, v := range jsonMap {
+		if v == nil {
+			continue
+		}
+		switch k {
+`)
+	// handle defined properties
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+
+		if f.UnmarshalName == "-" {
+			continue
+		}
+
+		emitUnmarshalFieldCode(w, g, f, imports)
+
+		if f.Required {
+			fmt.Fprintf(w, "\t\t\treceived%s = true\n", f.Name)
+		}
+	}
+
+	// handle pattern and additional properties
+	if s.AdditionalType != "" || s.CaptureUnknown || s.PatternPropertiesPattern != "" {
+		fmt.Fprintf(w, "\t\tdefault:\n")
+	}
+	if s.PatternPropertiesPattern != "" {
+		fmt.Fprintf(w, `			if patternProperties%[1]s.MatchString(k) {
+				var patternValue %[2]s
+				if err := json.Unmarshal([]byte(v), &patternValue); err != nil {
+					return err
+				}
+				if strct.PatternProperties == nil {
+					strct.PatternProperties = make(map[string]%[2]s)
+				}
+				strct.PatternProperties[k] = patternValue
+				continue
+			}
+`, s.Name, s.PatternPropertiesType)
+		if s.AdditionalType == "" && !s.CaptureUnknown {
+			// there is nowhere else to keep the property
+			args := "k, patternProperties" + s.Name + ".String()"
+			fmt.Fprintf(w, "\t\t\treturn %s\n",
+				getFormattedCheckError(g, "k", "additionalProperties", "property %q does not match the pattern %q", args, imports))
+		}
+	}
+	if s.AdditionalType != "" {
+		if s.AdditionalType == "false" {
+			// all unknown properties are not allowed
+			if g.DisallowUnknownFields {
+				unknown := getFormattedCheckError(g, "k", "additionalProperties", "unknown field %q", "k", imports)
+				fmt.Fprintf(w, "\t\t\treturn %s\n", unknown)
+			} else {
+				fmt.Fprintf(w, `			continue
+`)
+			}
+		} else {
+			if checkPropertyNames {
+				fmt.Fprintf(w, `			if !propertyNames%s.MatchString(k) {
+				return %s
+			}
+`, s.Name, getPropertyNamesError(g, s, imports))
+			}
+			fmt.Fprintf(w, `			// an additional "%s" value
+			var additionalValue %s
+			if err := json.Unmarshal([]byte(v), &additionalValue); err != nil {
+				return err // invalid additionalProperty
+			}
+			if strct.AdditionalProperties == nil {
+`, s.AdditionalType, s.AdditionalType)
+			emitAdditionalPropertiesMakeCode(w, s)
+			fmt.Fprintf(w, `			}
+			strct.AdditionalProperties[k]= additionalValue
+`)
+		}
+	}
+	if s.CaptureUnknown {
+		fmt.Fprintf(w, `			// keep the unknown property as it is
+			if strct.Extra == nil {
+				strct.Extra = make(map[string]json.RawMessage)
+			}
+			strct.Extra[k] = v
+`)
+	}
+	fmt.Fprintf(w, "\t\t}\n") // switch
+	fmt.Fprintf(w, "\t}\n")   // for
+}
+
+func emitDependentRequiredCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	properties := make([]string, 0, len(s.DependentRequired))
+	for property := range s.DependentRequired {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	for _, property := range properties {
+		fmt.Fprintf(w, "\t// the properties required when %s is present\n", property)
+		fmt.Fprintf(w, "\tif _, ok := jsonMap[%q]; ok {\n", property)
+		for _, name := range s.DependentRequired[property] {
+			message := fmt.Sprintf("%q is required when %q is present", name, property)
+			fmt.Fprintf(w, `		if _, ok := jsonMap[%q]; !ok {
+			return %s
+		}
+`, name, getCheckError(g, name, "dependentRequired", message, imports))
+		}
+		fmt.Fprintf(w, "\t}\n")
+	}
+}
+
+func emitConditionCode(w io.Writer, g *Generator, c Condition, imports map[string]bool) {
+	value := "strct." + c.Field.Name
+	present := ""
+	if strings.HasPrefix(c.Field.MarshalType, "*") {
+		// a null value leaves the pointer nil
+		present = value + " != nil && "
+		value = "*" + value
+	}
+	constJSON, _ := json.Marshal(c.Value)
+	literal := string(constJSON)
+	if s, ok := c.Value.(string); ok {
+		literal = strconv.Quote(s)
+	}
+
+	// an absent property matches the if schema unless it is required by it
+	matches := fmt.Sprintf("!ok || %s%s == %s", present, value, literal)
+	differs := fmt.Sprintf("ok && !(%s%s == %s)", present, value, literal)
+	if c.PropertyRequired {
+		matches = fmt.Sprintf("ok && %s%s == %s", present, value, literal)
+		differs = fmt.Sprintf("!ok || !(%s%s == %s)", present, value, literal)
+	}
+
+	emit := func(check, relation string, required []string) {
+		if len(required) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\tif _, ok := jsonMap[%q]; %s {\n", c.Property, check)
+		for _, name := range required {
+			message := fmt.Sprintf("%q is required when %q %s %s", name, c.Property, relation, constJSON)
+			fmt.Fprintf(w, `		if _, ok := jsonMap[%q]; !ok {
+			return %s
+		}
+`, name, getCheckError(g, name, "required", message, imports))
+		}
+		fmt.Fprintf(w, "\t}\n")
+	}
+	fmt.Fprintf(w, "\t// the properties required by the if/then/else schema\n")
+	emit(matches, "is", c.Then)
+	emit(differs, "is not", c.Else)
+}
+
+// returns the field of the property with the JSON name if whether it is set can be told, which is when it's a pointer
+// or omitempty, the other fields are always marshalled
+func getSetField(s Struct, name string) (Field, bool) {
+	for _, f := range s.Fields {
+		if f.UnmarshalName == name && f.UnmarshalName != "-" {
+			return f, f.OmitEmpty || strings.HasPrefix(f.MarshalType, "*")
+		}
+	}
+	return Field{}, false
+}
+
+// returns the properties required when the property is present whose fields can be told to be set, for Validate
+func getSetFields(s Struct, names []string) []Field {
+	fields := []Field{}
+	for _, name := range names {
+		if f, ok := getSetField(s, name); ok {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// returns true when Validate checks the number of properties or which are required by the presence or value of
+// another, for which UnmarshalJSON looks at the JSON instead
+func hasPresenceValidation(s Struct) bool {
+	if s.MinProperties != nil || s.MaxProperties != nil {
+		return true
+	}
+	for property, required := range s.DependentRequired {
+		if _, ok := getSetField(s, property); ok && len(getSetFields(s, required)) > 0 {
+			return true
+		}
+	}
+	for _, c := range s.Conditions {
+		if _, ok := getSetField(s, c.Property); ok && len(getSetFields(s, c.Then))+len(getSetFields(s, c.Else)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writes the checks of the properties required by dependentRequired and if/then/else for Validate, with the fields
+// which are set standing for the properties which are present
+func emitPresenceChecks(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	emit := func(check string, message string, rule string, required []Field) {
+		fmt.Fprintf(w, "\tif %s {\n", check)
+		for _, f := range required {
+			err := getCheckError(g, f.UnmarshalName, rule, fmt.Sprintf("%q is required when %s", f.UnmarshalName, message),
+				imports)
+			fmt.Fprintf(w, `		if %s {
+			%s
+		}
+`, getZeroCheck("strct."+f.Name, f.MarshalType, imports), getValidateFailure(g, err))
+		}
+		fmt.Fprintf(w, "\t}\n")
+	}
+	properties := make([]string, 0, len(s.DependentRequired))
+	for property := range s.DependentRequired {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	for _, property := range properties {
+		f, ok := getSetField(s, property)
+		required := getSetFields(s, s.DependentRequired[property])
+		if !ok || len(required) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\t// the properties required when %s is set\n", property)
+		emit(getNonZeroCheck("strct."+f.Name, f.MarshalType, imports), fmt.Sprintf("%q is present", property),
+			"dependentRequired", required)
+	}
+	for _, c := range s.Conditions {
+		f, ok := getSetField(s, c.Property)
+		then, otherwise := getSetFields(s, c.Then), getSetFields(s, c.Else)
+		if !ok || len(then)+len(otherwise) == 0 {
+			continue
+		}
+		set := getNonZeroCheck("strct."+f.Name, f.MarshalType, imports)
+		value := "strct." + f.Name
+		if strings.HasPrefix(f.MarshalType, "*") {
+			value = "*" + value
+		}
+		constJSON, _ := json.Marshal(c.Value)
+		literal := string(constJSON)
+		if str, ok := c.Value.(string); ok {
+			literal = strconv.Quote(str)
+		}
+		// a property which isn't set matches the if schema unless it is required by it
+		matches := fmt.Sprintf("!(%s) || %s == %s", set, value, literal)
+		differs := fmt.Sprintf("%s && %s != %s", set, value, literal)
+		if c.PropertyRequired {
+			matches = fmt.Sprintf("%s && %s == %s", set, value, literal)
+			differs = fmt.Sprintf("!(%s) || %s != %s", set, value, literal)
+		}
+		fmt.Fprintf(w, "\t// the properties required by the if/then/else schema\n")
+		if len(then) > 0 {
+			emit(matches, fmt.Sprintf("%q is %s", c.Property, constJSON), "required", then)
+		}
+		if len(otherwise) > 0 {
+			emit(differs, fmt.Sprintf("%q is not %s", c.Property, constJSON), "required", otherwise)
+		}
+	}
+}
+
+// returns true when the struct has constraints for the generated Validate method to check
+func hasStructValidation(s Struct) bool {
+	if hasPresenceValidation(s) {
+		return true
+	}
+	for _, f := range s.Fields {
+		if hasValidation(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func emitValidateCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	checkPresence := hasPresenceValidation(s)
+	if checkPresence {
+		// UnmarshalJSON checks the presence of the properties in the JSON itself and the rest with validate(false)
+		fmt.Fprintf(w, `
+// Validate checks the values of the fields against the constraints of the schema. Whether a property is present
+// can only be told for the pointer and omitempty fields, so only the ones of those which are set and the additional
+// properties count towards minProperties and maxProperties, and only those fields are checked for the properties
+// dependentRequired and if/then/else require.
+func (strct *%[1]s) Validate() error {
+	return strct.validate(true)
+}
+
+func (strct *%[1]s) validate(checkPresence bool) error {
+`, s.Name)
+	} else {
+		fmt.Fprintf(w, `
+// Validate checks the values of the fields against the constraints of the schema.
+func (strct *%s) Validate() error {
+`, s.Name)
+	}
+	if g.CollectErrors {
+		fmt.Fprintf(w, "\tvar errs ValidationErrors\n")
+	}
+
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		value := "strct." + f.Name
+		typ := f.MarshalType
+		present := ""
+		if strings.HasPrefix(typ, "*") {
+			// absent optional values are not checked
+			present = value + " != nil && "
+			value = "*" + value
+			typ = typ[1:]
+		}
+		if f.MultipleOf != nil {
+			emitMultipleOfCheck(w, g, f, present, value, typ, imports)
+		}
+		if hasFormatValidation(f) {
+			if present == "" && !f.Required {
+				// an optional string which is empty wasn't set
+				present = value + ` != "" && `
+			}
+			message := fmt.Sprintf("%q is not a valid %s", f.UnmarshalName, f.Format)
+			fmt.Fprintf(w, `	if %s!format%s.MatchString(%s) {
+		%s
+	}
+`, present, getGolangName(f.Format), value, getValidateFailure(g, getCheckError(g, f.UnmarshalName, "format", message, imports)))
+		}
+		for _, literal := range getTypedEnum(f.NotValues, typ) {
+			message := fmt.Sprintf("%q must not be %s", f.UnmarshalName, literal)
+			fmt.Fprintf(w, `	if %s%s == %s {
+		%s
+	}
+`, present, value, literal, getValidateFailure(g, getCheckError(g, f.UnmarshalName, "not", message, imports)))
+		}
+		if getContainsCondition(f, "item") != "" {
+			emitContainsCheck(w, g, f, imports)
+		}
+	}
+	if checkPresence {
+		fmt.Fprintf(w, "\tif checkPresence {\n")
+		if s.MinProperties != nil || s.MaxProperties != nil {
+			emitPropertyCountCheck(w, g, s, false, imports)
+		}
+		emitPresenceChecks(w, g, s, imports)
+		fmt.Fprintf(w, "\t}\n")
+	}
+
+	if g.CollectErrors {
+		fmt.Fprintf(w, `	if len(errs) > 0 {
+		return errs
+	}
+`)
+	}
+	fmt.Fprintf(w, "\treturn nil\n")
+	fmt.Fprintf(w, "}\n") // Validate
+}
+
+func emitContainsCheck(w io.Writer, g *Generator, f Field, imports map[string]bool) {
+	minContains := 1
+	if f.MinContains != nil {
+		minContains = *f.MinContains
+	}
+	// an optional array which is nil wasn't set
+	present := ""
+	if !f.Required {
+		present = "strct." + f.Name + " != nil && "
+	}
+	count := "contains" + f.Name
+	fmt.Fprintf(w, `	// count the items of "%[1]s" which match the contains schema
+	%[2]s := 0
+	for _, item := range strct.%[3]s {
+		if %[4]s {
+			%[2]s++
+		}
+	}
+`, f.UnmarshalName, count, f.Name, getContainsCondition(f, "item"))
+	if minContains > 0 {
+		rule := "contains"
+		if f.MinContains != nil {
+			rule = "minContains"
+		}
+		message := fmt.Sprintf("%q must contain at least %d matching items", f.UnmarshalName, minContains)
+		fmt.Fprintf(w, `	if %s%s < %d {
+		%s
+	}
+`, present, count, minContains, getValidateFailure(g, getCheckError(g, f.UnmarshalName, rule, message, imports)))
+	}
+	if f.MaxContains != nil {
+		message := fmt.Sprintf("%q must contain at most %d matching items", f.UnmarshalName, *f.MaxContains)
+		fmt.Fprintf(w, `	if %s > %d {
+		%s
+	}
+`, count, *f.MaxContains, getValidateFailure(g, getCheckError(g, f.UnmarshalName, "maxContains", message, imports)))
+	}
+}
+
+// unmarshalling: the properties of the JSON are counted, otherwise the pointer and omitempty fields which are set
+// and the additional properties, as whether the other fields are set can't be told
+func emitPropertyCountCheck(w io.Writer, g *Generator, s Struct, unmarshalling bool, imports map[string]bool) {
+	failure := func(err string) string {
+		if unmarshalling {
+			return "return " + err
+		}
+		return getValidateFailure(g, err)
+	}
+	if unmarshalling {
+		fmt.Fprintf(w, "\t// count the properties of the JSON, including the null ones\n")
+		fmt.Fprintf(w, "\tproperties := len(jsonMap)\n")
+	} else {
+		fmt.Fprintf(w, "\t// count the pointer and omitempty fields which are set and the other properties\n")
+		fmt.Fprintf(w, "\tproperties := 0\n")
+		for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+			f := s.Fields[fieldKey]
+			if f.MarshalName == "-" {
+				if f.Name != "AdditionalOrder" {
+					fmt.Fprintf(w, "\tproperties += len(strct.%s)\n", f.Name)
+				}
+				continue
+			}
+			if !f.OmitEmpty && !strings.HasPrefix(f.MarshalType, "*") {
+				continue
+			}
+			fmt.Fprintf(w, "\tif %s {\n", getNonZeroCheck("strct."+f.Name, f.MarshalType, imports))
+			fmt.Fprintf(w, "\t\tproperties++\n")
+			fmt.Fprintf(w, "\t}\n")
+		}
+	}
+	if s.MinProperties != nil {
+		message := fmt.Sprintf("%s must have at least %d properties", s.Name, *s.MinProperties)
+		fmt.Fprintf(w, `	if properties < %d {
+		%s
+	}
+`, *s.MinProperties, failure(getCheckError(g, "", "minProperties", message, imports)))
+	}
+	if s.MaxProperties != nil {
+		message := fmt.Sprintf("%s must have at most %d properties", s.Name, *s.MaxProperties)
+		fmt.Fprintf(w, `	if properties > %d {
+		%s
+	}
+`, *s.MaxProperties, failure(getCheckError(g, "", "maxProperties", message, imports)))
+	}
+}
+
+// emits the regular expressions of the formats which are validated, once for all structs
+func emitFormatPatterns(w io.Writer, g *Generator, imports map[string]bool) {
+	used := map[string]bool{}
+	for _, s := range g.Structs {
+		for _, f := range s.Fields {
+			if hasFormatValidation(f) && s.GenerateCode {
+				used[f.Format] = true
+			}
+		}
+	}
+	for _, format := range getOrderedKeys(used) {
+		imports["regexp"] = true
+		fmt.Fprintf(w, `
+// the pattern values with the %q format must match
+var format%s = regexp.MustCompile(%q)
+`, format, getGolangName(format), formatPatterns[format])
+	}
+}
+
+func emitMultipleOfCheck(w io.Writer, g *Generator, f Field, present string, value string, typ string,
+	imports map[string]bool) {
+	m := *f.MultipleOf
+	message := fmt.Sprintf("%q must be a multiple of %s", f.UnmarshalName, strconv.FormatFloat(m, 'f', -1, 64))
+	check := getValidateFailure(g, getCheckError(g, f.UnmarshalName, "multipleOf", message, imports))
+	if typ == "int" && m == math.Trunc(m) {
+		fmt.Fprintf(w, `	if %s%s%%%d != 0 {
+		%s
+	}
+`, present, value, int64(m), check)
+		return
+	}
+	// floating point numbers can't represent most decimal multiples exactly, e.g. 0.3 / 0.1 is
+	// 2.9999999999999996, so allow for a small error in the quotient
+	imports["math"] = true
+	quotient := fmt.Sprintf("float64(%s)/%s", value, strconv.FormatFloat(m, 'g', -1, 64))
+	fmt.Fprintf(w, `	// the quotient is allowed to be off by a small tolerance to allow for floating point imprecision
+	if %smath.Abs(%s-math.Round(%s)) > 1e-9 {
+		%s
+	}
+`, present, quotient, quotient, check)
+}
+
+// writes the code which puts the field into the map as MarshalJSON writes it, with the same encoding and left out in
+// the same cases
+func emitToMapFieldCode(w io.Writer, f Field, imports map[string]bool) {
+	set := fmt.Sprintf("\tm[\"%s\"] = %s\n", f.MarshalName, getEncodedValue(f, imports))
+	if f.NilAsEmpty {
+		set = fmt.Sprintf(`	if strct.%[2]s == nil {
+		m["%[1]s"] = %[3]s{}
+	} else {
+		m["%[1]s"] = strct.%[2]s
+	}
+`, f.MarshalName, f.Name, f.MarshalType)
+	}
+	if f.FloatSpecials != "" {
+		set = getFloatSpecialsToMapCode(f, imports)
+	}
+
+	if f.OmitEmpty {
+		fmt.Fprintf(w, "\tif %s {\n%s\t}\n", getNonZeroCheck("strct."+f.Name, f.MarshalType, imports), indentCode(set))
+	} else if f.OmitNull && isNillable(f.MarshalType) {
+		fmt.Fprintf(w, "\tif strct.%s != nil {\n%s\t}\n", f.Name, indentCode(set))
+	} else {
+		fmt.Fprint(w, set)
+	}
+}
+
+// returns the code which puts a float field with FloatSpecials into the map, like getFloatSpecialsMarshalCode
+func getFloatSpecialsToMapCode(f Field, imports map[string]bool) string {
+	imports["math"] = true
+	value := "strct." + f.Name
+	check := fmt.Sprintf("math.IsNaN(%[1]s) || math.IsInf(%[1]s, 0)", value)
+	if strings.HasPrefix(f.MarshalType, "*") {
+		value = "*" + value
+		check = fmt.Sprintf("strct.%[1]s != nil && (math.IsNaN(%[2]s) || math.IsInf(%[2]s, 0))", f.Name, value)
+	}
+	special := "nil"
+	if f.FloatSpecials == "string" {
+		imports["strconv"] = true
+		special = fmt.Sprintf("strconv.FormatFloat(%s, 'g', -1, 64)", value)
+	}
+	return fmt.Sprintf(`	if %[3]s {
+		m["%[1]s"] = %[4]s
+	} else {
+		m["%[1]s"] = strct.%[2]s
+	}
+`, f.MarshalName, f.Name, check, special)
+}
+
+func emitToMapFuncsCode(w io.Writer, g *Generator) {
+	fmt.Fprintf(w, `
+// ToMapFuncs holds the ToMap method of each struct by its name, the functions take a value of, or a pointer to,
+// the struct and return nil for anything else.
+var ToMapFuncs = map[string]func(any) map[string]any{
+`)
+	for _, k := range getOrderedStructNames(g.Structs) {
+		s := g.Structs[k]
+		if !s.GenerateCode {
+			// ToMap is only generated with the other methods
+			continue
+		}
+		fmt.Fprintf(w, `	%[1]q: func(v any) map[string]any {
+		switch v := v.(type) {
+		case *%[1]s:
+			return v.ToMap()
+		case %[1]s:
+			return v.ToMap()
+		}
+		return nil
+	},
+`, s.Name)
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+// returns the expression for the value of the field which is marshalled, encoding []byte fields as needed
+func getEncodedValue(f Field, imports map[string]bool) string {
+	switch f.ContentEncoding {
+	case "base64":
+		imports["encoding/base64"] = true
+		return "base64.StdEncoding.EncodeToString(strct." + f.Name + ")"
+	case "base64url":
+		imports["encoding/base64"] = true
+		return "base64.URLEncoding.EncodeToString(strct." + f.Name + ")"
+	}
+	if f.NumberAsString {
+		imports["strconv"] = true
+		if f.MarshalType == "int" {
+			return "strconv.Itoa(strct." + f.Name + ")"
+		}
+		return "strconv.FormatFloat(strct." + f.Name + ", 'g', -1, 64)"
+	}
+	switch f.DurationFormat {
+	case "iso8601":
+		return "formatISODuration(strct." + f.Name + ")"
+	case "go":
+		return "strct." + f.Name + ".String()"
+	default:
+		return "strct." + f.Name
+	}
+}
+
+func emitToMapCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	// ToMap code
+	fmt.Fprintf(w, `
+func (strct *%s) ToMap() map[string]any {
+`, s.Name)
+
+	fmt.Fprintf(w, "\tm := make(map[string]any)\n")
+
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		if f.MarshalName == "-" {
+			// the field isn't part of the JSON either
+			if g.MapIncludesHidden {
+				fmt.Fprintf(w, "\tm[%q] = strct.%s\n", f.Name, f.Name)
+			}
+			continue
+		}
+		emitToMapFieldCode(w, f, imports)
+	}
+
+	fmt.Fprintf(w, "\treturn m\n")
+	fmt.Fprintf(w, "}\n") // ToMap
+}
+
+// only emitted for string aliases: encoding/json encodes a TextMarshaler as a JSON string, which would change the
+// representation of any other type
+func emitTextMarshalCode(w io.Writer, a Field, imports map[string]bool) {
+	if a.Pattern != "" {
+		imports["regexp"] = true
+		fmt.Fprintf(w, `
+// the pattern values of %s must match
+var pattern%s = regexp.MustCompile(%q)
+`, a.Name, a.Name, a.Pattern)
+	}
+
+	fmt.Fprintf(w, `
+// MarshalText implements encoding.TextMarshaler.
+func (value %[1]s) MarshalText() ([]byte, error) {
+	return []byte(value), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (value *%[1]s) UnmarshalText(b []byte) error {
+	v := %[1]s(b)
+`, a.Name)
+
+	if len(a.Enum) > 0 {
+		imports["fmt"] = true
+		values := make([]string, len(a.Enum))
+		for i, e := range a.Enum {
+			values[i] = strconv.Quote(fmt.Sprint(e))
+		}
+		fmt.Fprintf(w, `	switch v {
+	case %s:
+	default:
+		return fmt.Errorf("%%q is not a valid %s", string(v))
+	}
+`, strings.Join(values, ", "), a.Name)
+	}
+	if a.Pattern != "" {
+		imports["fmt"] = true
+		fmt.Fprintf(w, `	if !pattern%[1]s.MatchString(string(v)) {
+		return fmt.Errorf("%%q does not match the pattern of %[1]s", string(v))
+	}
+`, a.Name)
+	}
+
+	fmt.Fprintf(w, `	*value = v
+	return nil
+}
+`)
+}
+
+// writes UnmarshalEnvelope if there are structs with an envelope type
+func emitEnvelopeCode(w io.Writer, g *Generator, imports map[string]bool) {
+	cases := ""
+	for _, k := range getOrderedStructNames(g.Structs) {
+		s := g.Structs[k]
+		if s.EnvelopeType == "" {
+			continue
+		}
+		cases += fmt.Sprintf(`	case %q:
+		var v %s
+		if err := json.Unmarshal(envelope.Data, &v); err != nil {
+			return nil, err
+		}
+		return &v, nil
+`, s.EnvelopeType, s.Name)
+	}
+	if cases == "" {
+		return
+	}
+	imports["encoding/json"] = true
+	imports["fmt"] = true
+	fmt.Fprintf(w, `
+// UnmarshalEnvelope unmarshals the data of an envelope like {"type": "...", "data": {...}} into a pointer to the
+// struct its type names.
+func UnmarshalEnvelope(b []byte) (any, error) {
+	var envelope struct {
+		Type string          %[1]sjson:"type"%[1]s
+		Data json.RawMessage %[1]sjson:"data"%[1]s
+	}
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return nil, err
+	}
+	switch envelope.Type {
+%[2]s	}
+	return nil, fmt.Errorf("unknown envelope type %%q", envelope.Type)
+}
+`, "`", cases)
+}
+
+// returns true when a struct keeps the order of its additional properties
+func usesOrderedAdditional(g *Generator) bool {
+	for _, s := range g.Structs {
+		if s.OrderedAdditional {
+			return true
+		}
+	}
+	return false
+}
+
+func emitObjectKeysCode(w io.Writer, imports map[string]bool) {
+	imports["bytes"] = true
+	imports["encoding/json"] = true
+	fmt.Fprintf(w, `
+// getObjectKeys returns the keys of the JSON object in the order they're in, none for null.
+func getObjectKeys(b []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t == nil {
+		return nil, err
+	}
+	keys := []string{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, t.(string))
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+`)
+}
+
+func emitCanonicalJSONCode(w io.Writer, imports map[string]bool) {
+	imports["bytes"] = true
+	imports["encoding/json"] = true
+	imports["fmt"] = true
+	imports["sort"] = true
+	imports["strconv"] = true
+	imports["strings"] = true
+	imports["unicode/utf16"] = true
+	fmt.Fprintf(w, `
+// canonicalJSON writes the JSON value as the JSON Canonicalization Scheme (RFC 8785) does: without whitespace, with
+// the keys of the objects sorted by their UTF-16 code units, the strings escaped as little as possible and the
+// numbers written like JavaScript does.
+func canonicalJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case string:
+		writeCanonicalString(buf, v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteString(formatCanonicalNumber(f))
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	default:
+		return fmt.Errorf("unexpected JSON value %%v", v)
+	}
+	return nil
+}
+
+// lessUTF16 compares the strings by their UTF-16 code units, which orders some characters outside the Basic
+// Multilingual Plane differently than their UTF-8 bytes.
+func lessUTF16(a, b string) bool {
+	x, y := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if x[i] != y[i] {
+			return x[i] < y[i]
+		}
+	}
+	return len(x) < len(y)
+}
+
+// writeCanonicalString only escapes the quote, the backslash and the control characters.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case '\b':
+			buf.WriteString("\\b")
+		case '\t':
+			buf.WriteString("\\t")
+		case '\n':
+			buf.WriteString("\\n")
+		case '\f':
+			buf.WriteString("\\f")
+		case '\r':
+			buf.WriteString("\\r")
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, "\\u%%04x", r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// formatCanonicalNumber writes the number with the fewest digits which read back as it, in decimal notation from
+// 1e-6 up to 1e21 and in exponential notation like 1e+21 otherwise.
+func formatCanonicalNumber(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	e, _ := strconv.Atoi(exponent)
+	// the position of the decimal point after the first n digits
+	n := e + 1
+	switch {
+	case len(digits) <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-len(digits))
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+	if len(digits) > 1 {
+		digits = digits[:1] + "." + digits[1:]
+	}
+	if e > 0 {
+		return sign + digits + "e+" + strconv.Itoa(e)
+	}
+	return sign + digits + "e" + strconv.Itoa(e)
+}
+`)
+}
+
+// returns true when a field is written in JSON as an ISO 8601 duration, so the helpers which convert them are needed
+func usesISODurations(g *Generator) bool {
+	for _, s := range g.Structs {
+		for _, f := range s.Fields {
+			if f.DurationFormat == "iso8601" && s.GenerateCode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// the durations are made of weeks, days, hours, minutes and seconds, years and months have no fixed length
+func emitISODurationCode(w io.Writer, imports map[string]bool) {
+	imports["fmt"] = true
+	imports["regexp"] = true
+	imports["strconv"] = true
+	imports["strings"] = true
+	imports["time"] = true
+	fmt.Fprintf(w, `
+// the ISO 8601 durations of weeks, days, hours, minutes and seconds
+var isoDurationPattern = regexp.MustCompile(%[1]s^(-)?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$%[1]s)
+
+// parseISODuration parses an ISO 8601 duration like P1DT2H30M.
+func parseISODuration(s string) (time.Duration, error) {
+	m := isoDurationPattern.FindStringSubmatch(s)
+	if m == nil || strings.Join(m[2:], "") == "" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %%q", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute} {
+		if m[i+2] != "" {
+			n, err := strconv.ParseInt(m[i+2], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid ISO 8601 duration %%q: %%w", s, err)
+			}
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[6] != "" {
+		seconds, err := strconv.ParseFloat(strings.Replace(m[6], ",", ".", 1), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %%q: %%w", s, err)
+		}
+		d += time.Duration(seconds * float64(time.Second))
+	}
+	if m[1] != "" {
+		d = -d
+	}
+	return d, nil
+}
+
+// formatISODuration formats the duration as an ISO 8601 duration of hours, minutes and seconds like PT26H0.5S.
+func formatISODuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	s := "PT"
+	if d < 0 {
+		s = "-PT"
+		d = -d
+	}
+	if h := d / time.Hour; h > 0 {
+		s += strconv.FormatInt(int64(h), 10) + "H"
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		s += strconv.FormatInt(int64(m), 10) + "M"
+		d -= m * time.Minute
+	}
+	if d > 0 {
+		s += strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+	}
+	return s
+}
+`, "`")
+}
+
+func emitEnumParserCode(w io.Writer, a Field, imports map[string]bool) {
+	imports["fmt"] = true
+	fmt.Fprintf(w, `
+// Parse%[1]s returns s as a %[1]s, or an error if it isn't one of the values of the enum.
+func Parse%[1]s(s string) (%[1]s, error) {
+	value := %[1]s(s)
+	if !value.Valid() {
+		return "", fmt.Errorf("%%q is not a valid %[1]s", s)
+	}
+	return value, nil
+}
+
+// Valid returns true when the value is one of the values of the enum.
+func (value %[1]s) Valid() bool {
+	switch value {
+	case %[2]s:
+		return true
+	}
+	return false
+}
+`, a.Name, strings.Join(getTypedEnum(a.Enum, "string"), ", "))
+}
+
+func emitAdditionalAccessorsCode(w io.Writer, s Struct) {
+	fmt.Fprintf(w, `
+// GetAdditional returns the additional property with the given key and whether it was present.
+func (strct *%[1]s) GetAdditional(key string) (%[2]s, bool) {
+	v, ok := strct.AdditionalProperties[key]
+	return v, ok
+}
+
+// SetAdditional sets the additional property with the given key.
+func (strct *%[1]s) SetAdditional(key string, v %[2]s) {
+	if strct.AdditionalProperties == nil {
+		strct.AdditionalProperties = make(map[string]%[2]s)
+	}
+`, s.Name, s.AdditionalType)
+	if s.OrderedAdditional {
+		fmt.Fprintf(w, `	if _, ok := strct.AdditionalProperties[key]; !ok {
+		strct.AdditionalOrder = append(strct.AdditionalOrder, key)
+	}
+`)
+	}
+	fmt.Fprintf(w, `	strct.AdditionalProperties[key] = v
+}
+`)
+}
+
+func emitAdditionalKeysCode(w io.Writer, s Struct, imports map[string]bool) {
+	imports["sort"] = true
+	fmt.Fprintf(w, `
+// AdditionalKeys returns the keys of the additional properties, sorted.
+func (strct *%s) AdditionalKeys() []string {
+	keys := make([]string, 0, len(strct.AdditionalProperties))
+	for k := range strct.AdditionalProperties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+`, s.Name)
+}
+
+func emitRequiredConstructorCode(w io.Writer, s Struct) {
+	params := []string{}
+	assignments := []string{}
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		if f.Discriminator != "" {
+			// the discriminator of a variant can only have one value
+			assignments = append(assignments, fmt.Sprintf("\t\t%s: %s,\n", f.Name,
+				getDiscriminatorConstName(f.MarshalType, f.Discriminator)))
+			continue
+		}
+		if !f.Required {
+			continue
+		}
+		param := getParameterName(f.Name)
+		params = append(params, param+" "+f.MarshalType)
+		assignments = append(assignments, fmt.Sprintf("\t\t%s: %s,\n", f.Name, param))
+	}
+
+	fmt.Fprintf(w, `
+// New%[1]s creates a %[1]s from its required fields, the optional fields are left as the zero value.
+func New%[1]s(%[2]s) %[1]s {
+	return %[1]s{
+%[3]s	}
+}
+`, s.Name, strings.Join(params, ", "), strings.Join(assignments, ""))
+}
+
+// returns true when the struct has a discriminator field, which its constructor sets
+func hasDiscriminator(s Struct) bool {
+	for _, f := range s.Fields {
+		if f.Discriminator != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the name of the constant of a value of the enum type of a discriminator, e.g. KindCat for "cat"
+func getDiscriminatorConstName(typ, value string) string {
+	return typ + getGolangName(value)
+}
+
+func emitDiscriminatorConstants(w io.Writer, a Field) {
+	values := make([]string, 0, len(a.Enum))
+	for _, e := range a.Enum {
+		values = append(values, e.(string))
+	}
+	sort.Strings(values)
+	fmt.Fprintf(w, "\n// the values of the %s discriminator\nconst (\n", a.Name)
+	for _, v := range values {
+		fmt.Fprintf(w, "\t%s %s = %q\n", getDiscriminatorConstName(a.Name, v), a.Name, v)
+	}
+	fmt.Fprintf(w, ")\n")
+}
+
+// returns the golang field name with a lower case first letter, for use as a parameter name
+func getParameterName(fieldName string) string {
+	r, size := utf8.DecodeRuneInString(fieldName)
+	name := string(unicode.ToLower(r)) + fieldName[size:]
+	if token.IsKeyword(name) {
+		return name + "_"
+	}
+	return name
+}
+
+func emitMergeCode(w io.Writer, s Struct, imports map[string]bool) {
+	fmt.Fprintf(w, `
+// Merge copies the fields of other which are not the zero value into strct.
+func (strct *%s) Merge(other %s) {
+`, s.Name, s.Name)
+
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		fmt.Fprintf(w, "\tif %s {\n", getNonZeroCheck("other."+f.Name, f.MarshalType, imports))
+		fmt.Fprintf(w, "\t\tstrct.%s = other.%s\n", f.Name, f.Name)
+		fmt.Fprintf(w, "\t}\n")
+	}
+
+	fmt.Fprintf(w, "}\n") // Merge
+}
+
+func emitDiffCode(w io.Writer, s Struct, imports map[string]bool) {
+	fmt.Fprintf(w, `
+// Diff returns the values of the fields of other which differ from strct, by their JSON name.
+func (strct %s) Diff(other %s) map[string]any {
+	diff := map[string]any{}
+`, s.Name, s.Name)
+
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		if f.MarshalName == "-" {
+			// the field isn't part of the JSON
+			continue
+		}
+		fmt.Fprintf(w, "\tif %s {\n", getDifferenceCheck("strct."+f.Name, "other."+f.Name, f.MarshalType, imports))
+		fmt.Fprintf(w, "\t\tdiff[%q] = other.%s\n", f.MarshalName, f.Name)
+		fmt.Fprintf(w, "\t}\n")
+	}
+
+	fmt.Fprintf(w, "\treturn diff\n")
+	fmt.Fprintf(w, "}\n") // Diff
+}
+
+func emitReaderInterfaceCode(w io.Writer, s Struct) {
+	fmt.Fprintf(w, `
+// %[1]sReader gives read access to the fields of %[1]s.
+type %[1]sReader interface {
+`, s.Name)
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		fmt.Fprintf(w, "\tGet%s() %s\n", f.Name, f.MarshalType)
+	}
+	fmt.Fprintf(w, `}
+
+// %[1]s has to implement %[1]sReader
+var _ %[1]sReader = %[1]s{}
+`, s.Name)
+
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		fmt.Fprintf(w, `
+// Get%[2]s returns the %[2]s field.
+func (strct %[1]s) Get%[2]s() %[3]s {
+	return strct.%[2]s
+}
+`, s.Name, f.Name, f.MarshalType)
+	}
+}
+
+func emitRandomCode(w io.Writer, g *Generator, s Struct, imports map[string]bool) {
+	imports["math/rand"] = true
+	fmt.Fprintf(w, `
+// GenerateRandom returns a %[1]s with random values within the constraints of the schema, e.g. for fuzzing.
+func (%[1]s) GenerateRandom(r *rand.Rand) %[1]s {
+	strct := %[1]s{}
+`, s.Name)
+
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		if f.MarshalType != f.UnmarshalType {
+			// the value would have to be valid in its JSON representation too
+			continue
+		}
+		typ := f.MarshalType
+		if name := strings.TrimPrefix(typ, "*"); strings.HasPrefix(typ, "*") && g.Structs[name].Name == name {
+			// optional structs are left out, otherwise recursive structs could never end
+			if f.Required {
+				fmt.Fprintf(w, "\t%s := %s{}.GenerateRandom(r)\n", "v"+f.Name, name)
+				fmt.Fprintf(w, "\tstrct.%s = &%s\n", f.Name, "v"+f.Name)
+			}
+			continue
+		}
+		if strings.HasPrefix(typ, "[]") {
+			if value, ok := getRandomValue(Field{}, typ[2:], imports); ok {
+				fmt.Fprintf(w, "\tstrct.%s = make(%s, r.Intn(4))\n", f.Name, typ)
+				fmt.Fprintf(w, "\tfor i := range strct.%s {\n", f.Name)
+				fmt.Fprintf(w, "\t\tstrct.%s[i] = %s\n", f.Name, value)
+				fmt.Fprintf(w, "\t}\n")
+			}
+			continue
+		}
+		value, ok := getRandomValue(f, strings.TrimPrefix(typ, "*"), imports)
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(typ, "*") {
+			fmt.Fprintf(w, "\tstrct.%s = %s\n", f.Name, value)
+			continue
+		}
+		indent := "\t"
+		// the value is prefixed so it can't shadow r or strct
+		if !f.Required {
+			// optional values are present half of the time
+			fmt.Fprintf(w, "\tif r.Intn(2) == 0 {\n")
+			indent = "\t\t"
+		}
+		fmt.Fprintf(w, "%s%s := %s\n", indent, "v"+f.Name, value)
+		fmt.Fprintf(w, "%sstrct.%s = &%s\n", indent, f.Name, "v"+f.Name)
+		if !f.Required {
+			fmt.Fprintf(w, "\t}\n")
+		}
+	}
+
+	fmt.Fprintf(w, "\treturn strct\n")
+	fmt.Fprintf(w, "}\n") // GenerateRandom
+}
+
+// returns an expression for a random value of the type within the constraints of the field, false for the types
+// which aren't supported
+func getRandomValue(f Field, typ string, imports map[string]bool) (string, bool) {
+	if enum := getTypedEnum(f.Enum, typ); len(enum) > 0 {
+		return fmt.Sprintf("[]%s{%s}[r.Intn(%d)]", typ, strings.Join(enum, ", "), len(enum)), true
+	}
+	switch typ {
+	case "bool":
+		return "r.Intn(2) == 1", true
+	case "int":
+		lo, hi := getRandomRange(f.Minimum, f.Maximum)
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+		if f.MultipleOf != nil && *f.MultipleOf == math.Trunc(*f.MultipleOf) {
+			m := *f.MultipleOf
+			kLo, kHi := math.Ceil(lo/m), math.Floor(hi/m)
+			if kHi < kLo {
+				return "", false
+			}
+			return fmt.Sprintf("(%d + r.Intn(%d)) * %d", int64(kLo), int64(kHi-kLo)+1, int64(m)), true
+		}
+		if hi < lo {
+			return "", false
+		}
+		return fmt.Sprintf("%d + r.Intn(%d)", int64(lo), int64(hi-lo)+1), true
+	case "float64":
+		lo, hi := getRandomRange(f.Minimum, f.Maximum)
+		if f.MultipleOf != nil {
+			m := *f.MultipleOf
+			kLo, kHi := math.Ceil(lo/m), math.Floor(hi/m)
+			if kHi < kLo {
+				return "", false
+			}
+			return fmt.Sprintf("float64(%d+r.Intn(%d)) * %s", int64(kLo), int64(kHi-kLo)+1, strconv.FormatFloat(m, 'g', -1, 64)), true
+		}
+		return fmt.Sprintf("%s + r.Float64()*%s", strconv.FormatFloat(lo, 'g', -1, 64), strconv.FormatFloat(hi-lo, 'g', -1, 64)), true
+	case "string":
+		switch f.Format {
+		case "email":
+			return `randomString(r, 1+r.Intn(8)) + "@example.com"`, true
+		case "uuid":
+			imports["fmt"] = true
+			return `fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", r.Uint32(), r.Intn(1<<16), r.Intn(1<<16), r.Intn(1<<16), r.Int63n(1<<48))`, true
+		}
+		lo := 0
+		if f.MinLength != nil {
+			lo = *f.MinLength
+		}
+		hi := lo + 10
+		if f.MaxLength != nil {
+			hi = *f.MaxLength
+		}
+		if hi < lo {
+			return "", false
+		}
+		return fmt.Sprintf("randomString(r, %d+r.Intn(%d))", lo, hi-lo+1), true
+	}
+	return "", false
+}
+
+// returns the range random numbers are taken from, numbers up to 100 apart from the bound when there is only one
+func getRandomRange(minimum, maximum *float64) (float64, float64) {
+	switch {
+	case minimum != nil && maximum != nil:
+		return *minimum, *maximum
+	case minimum != nil:
+		return *minimum, *minimum + 100
+	case maximum != nil:
+		return *maximum - 100, *maximum
+	}
+	return 0, 100
+}
+
+// returns the enum values which are of the golang type as literals
+func getTypedEnum(enum []interface{}, typ string) []string {
+	literals := []string{}
+	for _, v := range enum {
+		switch v := v.(type) {
+		case string:
+			if typ == "string" {
+				literals = append(literals, strconv.Quote(v))
+			}
+		case float64:
+			if typ == "float64" || (typ == "int" && v == math.Trunc(v)) {
+				literals = append(literals, strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		case bool:
+			if typ == "bool" {
+				literals = append(literals, strconv.FormatBool(v))
+			}
+		}
+	}
+	return literals
+}
+
+// returns true when the alias is a slice with constraints on its items, which its methods check
+func hasItemValidation(a Field) bool {
+	if a.Alias || !strings.HasPrefix(a.UnmarshalType, "[]") {
+		return false
+	}
+	return a.MinItems != nil || a.MaxItems != nil || len(getTypedEnum(a.ItemEnum, a.UnmarshalType[2:])) > 0
+}
+
+func emitItemValidationCode(w io.Writer, g *Generator, a Field, imports map[string]bool) {
+	imports["encoding/json"] = true
+	fmt.Fprintf(w, `
+// MarshalJSON checks the items of the %[1]s against the constraints of the schema before marshalling them.
+func (list %[1]s) MarshalJSON() ([]byte, error) {
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(%[2]s(list))
+}
+`, a.Name, a.UnmarshalType)
+	if !g.MarshalOnly {
+		fmt.Fprintf(w, `
+// UnmarshalJSON unmarshals the items of the %[1]s and checks them against the constraints of the schema.
+func (list *%[1]s) UnmarshalJSON(b []byte) error {
+	var items %[2]s
+	if err := json.Unmarshal(b, &items); err != nil {
+		return err
+	}
+	if err := %[1]s(items).Validate(); err != nil {
+		return err
+	}
+	*list = items
+	return nil
+}
+`, a.Name, a.UnmarshalType)
+	}
+	fmt.Fprintf(w, `
+// Validate checks the items of the %[1]s against the constraints of the schema.
+func (list %[1]s) Validate() error {
+`, a.Name)
+	if a.MinItems != nil {
+		message := fmt.Sprintf("%s must have at least %d items", a.Name, *a.MinItems)
+		fmt.Fprintf(w, `	if len(list) < %d {
+		return %s
+	}
+`, *a.MinItems, getCheckError(g, "", "minItems", message, imports))
+	}
+	if a.MaxItems != nil {
+		message := fmt.Sprintf("%s must have at most %d items", a.Name, *a.MaxItems)
+		fmt.Fprintf(w, `	if len(list) > %d {
+		return %s
+	}
+`, *a.MaxItems, getCheckError(g, "", "maxItems", message, imports))
+	}
+	if enum := getTypedEnum(a.ItemEnum, a.UnmarshalType[2:]); len(enum) > 0 {
+		fmt.Fprintf(w, `	for i, item := range list {
+		switch item {
+		case %s:
+		default:
+			return %s
+		}
+	}
+`, strings.Join(enum, ", "), getFormattedCheckError(g, `""`, "enum", "item %d of "+a.Name+" is not an allowed value", "i",
+			imports))
+	}
+	fmt.Fprintf(w, "\treturn nil\n")
+	fmt.Fprintf(w, "}\n") // Validate
+}
+
+func emitRequiredFieldsCode(w io.Writer, s Struct) {
+	names := []string{}
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		if f := s.Fields[fieldKey]; f.Required {
+			names = append(names, f.MarshalName)
+		}
+	}
+	sort.Strings(names)
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	fmt.Fprintf(w, `
+// %[1]sRequiredFields are the JSON names of the required properties of %[1]s, sorted.
+var %[1]sRequiredFields = []string{%[2]s}
+`, s.Name, strings.Join(quoted, ", "))
+}
+
+func emitFieldMapCode(w io.Writer, s Struct) {
+	fmt.Fprintf(w, `
+// %[1]sFieldMap maps the JSON names of the properties of %[1]s to the names of their fields.
+var %[1]sFieldMap = map[string]string{
+`, s.Name)
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		if f := s.Fields[fieldKey]; f.MarshalName != "-" {
+			fmt.Fprintf(w, "\t%q: %q,\n", f.MarshalName, f.Name)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+func emitSQLValuerCode(w io.Writer, s Struct, imports map[string]bool) {
+	imports["database/sql/driver"] = true
+	imports["encoding/json"] = true
+	imports["fmt"] = true
+	fmt.Fprintf(w, `
+// Scan implements sql.Scanner by unmarshalling the JSON of a database column.
+func (strct *%[1]s) Scan(src any) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, strct)
+	case string:
+		return json.Unmarshal([]byte(src), strct)
+	default:
+		return fmt.Errorf("cannot scan a %%T into a %[1]s", src)
+	}
+}
+
+// Value implements driver.Valuer by marshalling %[1]s to JSON.
+func (strct %[1]s) Value() (driver.Value, error) {
+	return json.Marshal(strct)
+}
+`, s.Name)
+}
+
+func emitExamplesCode(w io.Writer, s Struct, imports map[string]bool) {
+	imports["encoding/json"] = true
+	imports["fmt"] = true
+	fmt.Fprintf(w, `
+// %[1]sExamples returns the examples of %[1]s in the schema, or an error if one of them isn't a valid %[1]s.
+func %[1]sExamples() ([]%[1]s, error) {
+	raw := []string{
+`, s.Name)
+	for _, example := range s.Examples {
+		fmt.Fprintf(w, "\t\t%q,\n", example)
+	}
+	fmt.Fprintf(w, `	}
+	examples := make([]%s, len(raw))
+	for i, r := range raw {
+		if err := json.Unmarshal([]byte(r), &examples[i]); err != nil {
+			return nil, fmt.Errorf("example %%d: %%w", i, err)
+		}
+	}
+	return examples, nil
+}
+`, s.Name)
+}
+
+func emitResetCode(w io.Writer, s Struct) {
+	fmt.Fprintf(w, `
+// Reset sets the fields of strct to their zero values, maps and slices are emptied to keep their capacity.
+func (strct *%s) Reset() {
+`, s.Name)
+
+	kept := []string{}
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		f := s.Fields[fieldKey]
+		switch {
+		case strings.HasPrefix(f.MarshalType, "[]"):
+			kept = append(kept, fmt.Sprintf("\t\t%s: strct.%s[:0],\n", f.Name, f.Name))
+		case strings.HasPrefix(f.MarshalType, "map["):
+			fmt.Fprintf(w, "\tfor k := range strct.%s {\n", f.Name)
+			fmt.Fprintf(w, "\t\tdelete(strct.%s, k)\n", f.Name)
+			fmt.Fprintf(w, "\t}\n")
+			kept = append(kept, fmt.Sprintf("\t\t%s: strct.%s,\n", f.Name, f.Name))
+		}
+	}
+
+	fmt.Fprintf(w, "\t*strct = %s{\n%s\t}\n", s.Name, strings.Join(kept, ""))
+	fmt.Fprintf(w, "}\n") // Reset
+}
+
+func emitMarshalIndentCode(w io.Writer, s Struct, imports map[string]bool) {
+	imports["encoding/json"] = true
+	// json.MarshalIndent compacts and re-indents whatever MarshalJSON returns, so delegate to it
+	fmt.Fprintf(w, `
+// MarshalJSONIndent is like MarshalJSON but indents the output, see json.MarshalIndent.
+func (strct %s) MarshalJSONIndent(prefix, indent string) ([]byte, error) {
+	return json.MarshalIndent(strct, prefix, indent)
+}
+`, s.Name)
+}
+
+func emitSchemaCode(w io.Writer, s Struct) {
+	names := []string{}
+	for _, fieldKey := range getOrderedFieldNames(s.Fields) {
+		if f := s.Fields[fieldKey]; f.MarshalName != "-" {
+			names = append(names, strconv.Quote(f.MarshalName))
+		}
+	}
+	fmt.Fprintf(w, `
+// FieldNames returns the JSON names of the properties of %[1]s.
+func (strct %[1]s) FieldNames() []string {
+	return []string{%[2]s}
+}
+`, s.Name, strings.Join(names, ", "))
+
+	if s.RawSchema != "" {
+		fmt.Fprintf(w, `
+// JSONSchema returns the JSON schema %[1]s was generated from.
+func (strct %[1]s) JSONSchema() string {
+	return %[2]q
+}
+`, s.Name, s.RawSchema)
+	}
+}
+
+func outputNameAndDescriptionComment(name, title, description string, width int, w io.Writer) {
+	// the name is usually derived from the title, repeating it adds nothing
+	if getGolangName(title) == name {
+		title = ""
+	}
+	switch {
+	case title != "" && description != "":
+		outputComment(wrapComment(name+" "+title+"\n\n"+description, width), "", w)
+	case title != "":
+		outputComment(wrapComment(name+" "+title, width), "", w)
+	default:
+		outputComment(wrapComment(name+" "+description, width), "", w)
+	}
+}
+
+func outputFieldDescriptionComment(name, description string, width int, w io.Writer) {
+	fmt.Fprintln(w)
+	outputComment(wrapComment(name+" "+description, width), "\t", w)
+}
+
+// word wraps the lines of text which are longer than width once they're a comment, i.e. with the "// " in front of
+// them, 0 for no wrapping. Indented lines are preformatted text, so they're left as they are, as are words which are
+// too long for a line of their own.
+func wrapComment(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	width -= len("// ")
+	var wrapped []string
+	for _, line := range strings.Split(text, "\n") {
+		if len(line) <= width || strings.IndexFunc(line, unicode.IsSpace) == 0 {
+			wrapped = append(wrapped, line)
+			continue
+		}
+		current := ""
+		for _, word := range strings.Fields(line) {
+			if current != "" && len(current)+1+len(word) > width {
+				wrapped = append(wrapped, current)
+				current = ""
+			}
+			if current != "" {
+				current += " "
+			}
+			current += word
+		}
+		wrapped = append(wrapped, current)
+	}
+	return strings.Join(wrapped, "\n")
+}
+
+// writes text as a comment, one line per line of text. CRLF and CR line endings are treated like LF and trailing
+// whitespace is removed, so that no stray carriage returns end up in the output.
+func outputComment(text string, indent string, w io.Writer) {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			fmt.Fprintf(w, "%s//\n", indent)
+			continue
+		}
+		fmt.Fprintf(w, "%s// %s\n", indent, line)
+	}
+}
+
+// returns the description used for fields without one when every field has to be commented
+func getDefaultFieldDescription(f Field) string {
+	if f.UnmarshalName == "-" {
+		return "holds the properties which are not defined by the schema."
+	}
+	return fmt.Sprintf("is the %q property.", f.UnmarshalName)
 }
 
 func cleanPackageName(pkg string) string {
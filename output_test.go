@@ -1,91 +1,4640 @@
 package generate
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 )
 
+// generateFromJSON parses the JSON schema, lets configure set up any generator options and creates the types.
+func generateFromJSON(t testing.TB, schema string, configure func(g *Generator)) *Generator {
+	t.Helper()
+	uri, _ := url.Parse("file:///test.json")
+	root, err := ParseWithSchemaKeyRequired(schema, uri, false)
+	if err != nil {
+		t.Fatalf("failed to parse the schema: %v", err)
+	}
+	g := New(root)
+	if configure != nil {
+		configure(g)
+	}
+	if err := g.CreateTypes(); err != nil {
+		t.Fatalf("failed to create types: %v", err)
+	}
+	return g
+}
+
+// runGenerated compiles the code generated by g into package main together with the given main.go source,
+// runs it and returns what it wrote to stdout.
+func runGenerated(t testing.TB, g *Generator, mainSrc string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	return runFiles(t, map[string]string{
+		"generated.go": buf.String(),
+		"main.go":      mainSrc,
+	})
+}
+
+// runFiles runs the source files as package main of a module and returns what it wrote to stdout. It's only meant for
+// the tests of runtime behavior, the tests of what is emitted check the generated source instead.
+func runFiles(t testing.TB, files map[string]string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping the run of the generated code in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("skipping the run of the generated code without a go command")
+	}
+	dir := t.TempDir()
+	files["go.mod"] = "module generated\n\ngo 1.18\n"
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	// the generated code has no dependencies, so no flags of the environment are needed
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOTOOLCHAIN=local")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run the generated code: %v\n%s\ngenerated code:\n%s", err, stderr.String(), files["generated.go"])
+	}
+	return stdout.String()
+}
+
+// runTest is a test of the behavior of the code generated from a schema: the main function uses the generated code
+// and has to print the expected output.
+type runTest struct {
+	name      string
+	schema    string
+	configure func(g *Generator)
+	// check, if set, checks the generator before its code is run
+	check func(t *testing.T, g *Generator)
+	// main is the source of package main, which is run with the generated code
+	main     string
+	expected string
+	// files are other files of the module by their path in it, e.g. the packages of the TypeMapper
+	files map[string]string
+}
+
+// runTests generates the code of each test into a package of its own and builds them all into one program, which
+// is run with the package of each test to run its main function. Building them together takes a single go command.
+func runTests(t *testing.T, tests []runTest) {
+	if testing.Short() {
+		t.Skip("skipping the run of the generated code in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("skipping the run of the generated code without a go command")
+	}
+	dir := t.TempDir()
+	files := map[string]string{"go.mod": "module generated\n\ngo 1.18\n"}
+	packages := make([]string, len(tests))
+	t.Run("generate", func(t *testing.T) {
+		for i, test := range tests {
+			pkg := fmt.Sprintf("run%d", i)
+			t.Run(test.name, func(t *testing.T) {
+				g := generateFromJSON(t, test.schema, test.configure)
+				if test.check != nil {
+					test.check(t, g)
+				}
+				var buf bytes.Buffer
+				Output(&buf, g, pkg)
+				main := strings.Replace(test.main, "package main\n", "package "+pkg+"\n", 1)
+				files[pkg+"/generated.go"] = buf.String()
+				files[pkg+"/main.go"] = strings.Replace(main, "\nfunc main() {\n", "\nfunc Main() {\n", 1)
+				for name, content := range test.files {
+					files[name] = content
+				}
+				packages[i] = pkg
+			})
+		}
+	})
+
+	// the program runs the main function of the package it's given
+	var program strings.Builder
+	program.WriteString("package main\n\nimport (\n\t\"os\"\n\n")
+	for _, pkg := range packages {
+		if pkg != "" {
+			fmt.Fprintf(&program, "\t%q\n", "generated/"+pkg)
+		}
+	}
+	program.WriteString(")\n\nfunc main() {\n\tswitch os.Args[1] {\n")
+	for _, pkg := range packages {
+		if pkg != "" {
+			fmt.Fprintf(&program, "\tcase %q:\n\t\t%s.Main()\n", pkg, pkg)
+		}
+	}
+	program.WriteString("\t}\n}\n")
+	files["main.go"] = program.String()
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "build", "-o", "run", ".")
+	cmd.Dir = dir
+	// the generated code has no dependencies, so no flags of the environment are needed
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOTOOLCHAIN=local")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		// the errors name the files by the package of the test
+		for i, pkg := range packages {
+			if pkg != "" && strings.Contains(string(out), pkg+"/") {
+				t.Errorf("the code of %q doesn't build, generated code:\n%s", tests[i].name, files[pkg+"/generated.go"])
+			}
+		}
+		t.Fatalf("failed to build the generated code: %v\n%s", err, out)
+	}
+
+	t.Run("run", func(t *testing.T) {
+		for i, test := range tests {
+			if packages[i] == "" {
+				// the code couldn't be generated
+				continue
+			}
+			t.Run(test.name, func(t *testing.T) {
+				cmd := exec.Command(filepath.Join(dir, "run"), packages[i])
+				var stdout, stderr bytes.Buffer
+				cmd.Stdout = &stdout
+				cmd.Stderr = &stderr
+				if err := cmd.Run(); err != nil {
+					t.Fatalf("failed to run the generated code: %v\n%s\ngenerated code:\n%s", err, stderr.String(), files[packages[i]+"/generated.go"])
+				}
+				if actual := stdout.String(); actual != test.expected {
+					t.Errorf("expected %q, got %q", test.expected, actual)
+				}
+			})
+		}
+	})
+}
+
+func TestThatTheGeneratedCodeBehavesAsExpected(t *testing.T) {
+	runTests(t, []runTest{
+		{
+			name: "absent optional fields stay nil with OptionalAsPointer",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"id": { "type": "string" },
+					"name": { "type": "string" },
+					"count": { "type": "integer" }
+				},
+				"required": [ "id" ]
+			}`,
+			configure: func(g *Generator) {
+				g.OptionalAsPointer = true
+			},
+			check: func(t *testing.T, g *Generator) {
+				fields := g.Structs["Root"].Fields
+				if fields["Id"].MarshalType != "string" {
+					t.Errorf("expected the required field to stay a string, got %s", fields["Id"].MarshalType)
+				}
+				if fields["Name"].MarshalType != "*string" || fields["Count"].MarshalType != "*int" {
+					t.Errorf("expected the optional fields to be pointers, got %s and %s", fields["Name"].MarshalType, fields["Count"].MarshalType)
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	if err := json.Unmarshal([]byte("{\"id\": \"a\", \"count\": 0}"), &r); err != nil {
+		panic(err)
+	}
+	fmt.Println(r.Name == nil, r.Count != nil && *r.Count == 0)
+	b, err := json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(b))
+}
+`,
+			expected: "true true\n{\"count\":0,\"id\":\"a\"}\n",
+		},
+		{
+			name: "Merge only overwrites fields which are set",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"count": { "type": "integer" },
+					"tags": { "type": "array", "items": { "type": "string" } },
+					"pair": { "marshalType": "[2]int", "unmarshalType": "[2]int" }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.GenerateMerge = true
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	r := Root{Name: "original", Count: 1, Pair: [2]int{1, 2}}
+	r.Merge(Root{Count: 2, Tags: []string{"a"}})
+	fmt.Println(r.Name, r.Count, r.Tags, r.Pair)
+	r.Merge(Root{Pair: [2]int{3, 4}})
+	fmt.Println(r.Name, r.Count, r.Tags, r.Pair)
+}
+`,
+			expected: "original 2 [a] [1 2]\noriginal 2 [a] [3 4]\n",
+		},
+		{
+			name: "additional property names must match the property names pattern",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				},
+				"additionalProperties": { "type": "string" },
+				"propertyNames": { "pattern": "^(name|x-[a-z]+)$" }
+			}`,
+			check: func(t *testing.T, g *Generator) {
+				if p := g.Structs["Root"].PropertyNamesPattern; p != "^(name|x-[a-z]+)$" {
+					t.Errorf("expected the propertyNames pattern to be stored on the struct, got %q", p)
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	fmt.Println(json.Unmarshal([]byte("{\"name\": \"a\", \"x-extra\": \"b\"}"), &r), r.AdditionalProperties["x-extra"])
+	fmt.Println(json.Unmarshal([]byte("{\"name\": \"a\", \"Bad\": \"b\"}"), &r))
+}
+`,
+			expected: "<nil> b\nadditional property \"Bad\" does not match the pattern \"^(name|x-[a-z]+)$\"\n",
+		},
+		{
+			// the JSON of the map is compared with the marshalled JSON after both went through encoding/json, which sorts
+			// the keys
+			name: "ToMap holds the JSON representation of converted fields",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"count": { "type": "integer", "marshalType": "string" },
+					"data": { "type": "string", "contentEncoding": "base64" },
+					"name": { "type": "string", "omitEmpty": true },
+					"nick": { "type": [ "string", "null" ], "omitNull": true },
+					"tags": { "type": "array", "items": { "type": "string" } }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.EmptySliceNotNull = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	nick := "b"
+	for _, r := range []Root{{}, {Count: "42", Data: []byte("x"), Name: "a", Nick: &nick, Tags: []string{"c"}}} {
+		b, err := json.Marshal(r)
+		if err != nil {
+			panic(err)
+		}
+		var marshalled map[string]any
+		if err := json.Unmarshal(b, &marshalled); err != nil {
+			panic(err)
+		}
+		expected, _ := json.Marshal(marshalled)
+		actual, err := json.Marshal(r.ToMap())
+		fmt.Println(string(actual) == string(expected), string(actual), err)
+	}
+}
+`,
+			expected: `true {"count":"","data":"","tags":[]} <nil>
+true {"count":"42","data":"eA==","name":"a","nick":"b","tags":["c"]} <nil>
+`,
+		},
+		{
+			name: "multipleOf is validated",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"count": { "type": "integer", "multipleOf": 5 },
+					"step": { "type": "number", "multipleOf": 0.1 }
+				}
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, input := range []string{
+		"{\"count\": 10, \"step\": 0.3}",
+		"{\"count\": 7}",
+		"{\"step\": 0.35}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(input), &r))
+	}
+	fmt.Println((&Root{Count: 15, Step: 2.2}).Validate())
+}
+`,
+			expected: "<nil>\n\"count\" must be a multiple of 5\n\"step\" must be a multiple of 0.1\n<nil>\n",
+		},
+		{
+			name: "string aliases can be used as map keys with text marshalers",
+			schema: `{
+				"title": "Color",
+				"type": "string",
+				"enum": [ "red", "green" ],
+				"pattern": "^[a-z]+$"
+			}`,
+			configure: func(g *Generator) {
+				g.GenerateTextMarshalers = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, err := json.Marshal(map[Color]int{"red": 1, "green": 2})
+	fmt.Println(string(b), err)
+	var m map[Color]int
+	fmt.Println(json.Unmarshal([]byte("{\"red\": 3}"), &m), m["red"])
+	fmt.Println(json.Unmarshal([]byte("{\"blue\": 4}"), &m))
+}
+`,
+			expected: "{\"green\":2,\"red\":1} <nil>\n<nil> 3\n\"blue\" is not a valid Color\n",
+		},
+		{
+			name: "unknown fields are ignored without DisallowUnknownFields",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				},
+				"additionalProperties": false
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	fmt.Println(json.Unmarshal([]byte("{\"name\": \"a\"}"), &r))
+	fmt.Println(json.Unmarshal([]byte("{\"name\": \"a\", \"other\": 1}"), &r))
+}
+`,
+			expected: "<nil>\n<nil>\n",
+		},
+		{
+			name: "unknown fields are rejected with DisallowUnknownFields",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				},
+				"additionalProperties": false
+			}`,
+			configure: func(g *Generator) {
+				g.DisallowUnknownFields = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	fmt.Println(json.Unmarshal([]byte("{\"name\": \"a\"}"), &r))
+	fmt.Println(json.Unmarshal([]byte("{\"name\": \"a\", \"other\": 1}"), &r))
+}
+`,
+			expected: "<nil>\nunknown field \"other\"\n",
+		},
+		{
+			name: "unknown fields survive a round trip with PreserveUnknownFields",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.PreserveUnknownFields = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	input := "{\"name\":\"a\",\"unknown\":{\"b\":[1,2.50,\"c\"]},\"zzz\":null}"
+	var r Root
+	if err := json.Unmarshal([]byte(input), &r); err != nil {
+		panic(err)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(b) == input, string(r.Extra["unknown"]))
+}
+`,
+			expected: "true {\"b\":[1,2.50,\"c\"]}\n",
+		},
+		{
+			name: "integer fields marshal without scientific notation",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"count": { "type": "integer" },
+					"nullableCount": { "type": [ "integer", "null" ] }
+				},
+				"required": [ "count" ]
+			}`,
+			check: func(t *testing.T, g *Generator) {
+				fields := g.Structs["Root"].Fields
+				if fields["Count"].MarshalType != "int" || fields["NullableCount"].MarshalType != "*int" {
+					t.Errorf("expected integer types, got %s and %s", fields["Count"].MarshalType, fields["NullableCount"].MarshalType)
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	if err := json.Unmarshal([]byte("{\"count\": 1000000, \"nullableCount\": 1000000}"), &r); err != nil {
+		panic(err)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(b))
+	if err := json.Unmarshal([]byte("{\"count\": 1000000, \"nullableCount\": null}"), &r); err != nil {
+		panic(err)
+	}
+	b, err = json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(b))
+}
+`,
+			expected: "{\"count\":1000000,\"nullableCount\":1000000}\n{\"count\":1000000,\"nullableCount\":null}\n",
+		},
+		{
+			name: "email and UUID formats are validated",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"email": { "type": "string", "format": "email" },
+					"id": { "type": "string", "format": "uuid" }
+				},
+				"required": [ "id" ]
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, input := range []string{
+		"{\"email\": \"someone@example.com\", \"id\": \"123e4567-e89b-12d3-a456-426614174000\"}",
+		"{\"id\": \"123e4567-e89b-12d3-a456-426614174000\"}",
+		"{\"email\": \"someone.example.com\", \"id\": \"123e4567-e89b-12d3-a456-426614174000\"}",
+		"{\"email\": \"someone@example.com\", \"id\": \"123e4567-e89b-12d3-a456\"}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(input), &r))
+	}
+}
+`,
+			expected: "<nil>\n<nil>\n\"email\" is not a valid email\n\"id\" is not a valid uuid\n",
+		},
+		{
+			name: "additional properties are marshalled in sorted order",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				},
+				"additionalProperties": { "type": "integer" }
+			}`,
+			main: `package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+func main() {
+	r := Root{Name: "a", AdditionalProperties: map[string]int{}}
+	for i, k := range []string{"q", "w", "e", "r", "t", "y", "u", "i", "o", "p"} {
+		r.AdditionalProperties[k] = i
+	}
+	first, err := r.MarshalJSON()
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < 20; i++ {
+		b, err := r.MarshalJSON()
+		if err != nil {
+			panic(err)
+		}
+		if !bytes.Equal(first, b) {
+			panic("the output differs: " + string(b))
+		}
+	}
+	fmt.Println(string(first))
+}
+`,
+			expected: "{\"name\": \"a\", \"e\": 2, \"i\": 7, \"o\": 8, \"p\": 9, \"q\": 0, \"r\": 3, \"t\": 4, \"u\": 6, \"w\": 1, \"y\": 5}\n",
+		},
+		{
+			name: "content encoded strings are round tripped as bytes",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"std": { "type": "string", "contentEncoding": "base64", "contentMediaType": "image/png" },
+					"url": { "type": "string", "contentEncoding": "base64url" }
+				}
+			}`,
+			check: func(t *testing.T, g *Generator) {
+				if typ := g.Structs["Root"].Fields["Std"].MarshalType; typ != "[]byte" {
+					t.Errorf("expected the field to be []byte, got %s", typ)
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	if err := json.Unmarshal([]byte("{\"std\": \"+/8=\", \"url\": \"-_8\"}"), &r); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(r.Std, r.Url)
+	b, err := json.Marshal(r)
+	fmt.Println(string(b), err)
+	fmt.Println(json.Unmarshal([]byte("{\"std\": \"-_8=\"}"), &r) != nil)
+}
+`,
+			expected: "[251 255] [251 255]\n{\"std\":\"+/8=\",\"url\":\"-_8=\"} <nil>\ntrue\n",
+		},
+		{
+			name: "if, then and else require properties conditionally",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"type": { "type": "string" },
+					"y": { "type": "string" },
+					"z": { "type": "string" }
+				},
+				"if": { "properties": { "type": { "const": "X" } }, "required": [ "type" ] },
+				"then": { "required": [ "y" ] },
+				"else": { "required": [ "z" ] }
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"type\": \"X\", \"y\": \"a\"}",
+		"{\"type\": \"X\"}",
+		"{\"type\": \"W\", \"z\": \"a\"}",
+		"{\"type\": \"W\"}",
+		"{\"z\": \"a\"}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(j), &r))
+	}
+}
+`,
+			expected: `<nil>
+"y" is required when "type" is "X"
+<nil>
+"z" is required when "type" is not "X"
+<nil>
+`,
+		},
+		{
+			name: "omitNull leaves out nil values without omitEmpty",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"empty": { "type": "array", "items": { "type": "string" }, "omitEmpty": true },
+					"null": { "type": "array", "items": { "type": "string" }, "omitNull": true },
+					"plain": { "type": "array", "items": { "type": "string" } }
+				}
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, err := json.Marshal(Root{})
+	fmt.Println(string(b), err)
+	b, err = json.Marshal(Root{Empty: []string{}, Null: []string{}, Plain: []string{}})
+	fmt.Println(string(b), err)
+	b, err = json.Marshal(Root{Empty: []string{"a"}, Null: []string{"b"}, Plain: []string{"c"}})
+	fmt.Println(string(b), err)
+}
+`,
+			expected: `{"plain":null} <nil>
+{"empty":[],"null":[],"plain":[]} <nil>
+{"empty":["a"],"null":["b"],"plain":["c"]} <nil>
+`,
+		},
+		{
+			name: "schemaless properties pass through as raw JSON",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"anything": { "description": "can be any JSON." }
+				},
+				"required": [ "name" ]
+			}`,
+			check: func(t *testing.T, g *Generator) {
+				if typ := g.Structs["Root"].Fields["Anything"].MarshalType; typ != "json.RawMessage" {
+					t.Errorf("expected the field to be json.RawMessage, got %s", typ)
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"anything\":{\"b\":[1,2.50,\"x\"],\"a\":null},\"name\":\"a\"}",
+		"{\"anything\":1e3,\"name\":\"a\"}",
+		"{\"name\":\"a\"}",
+	} {
+		var r Root
+		if err := json.Unmarshal([]byte(j), &r); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		b, err := json.Marshal(r)
+		fmt.Println(string(b), err)
+	}
+}
+`,
+			expected: `{"anything":{"b":[1,2.50,"x"],"a":null},"name":"a"} <nil>
+{"anything":1e3,"name":"a"} <nil>
+{"anything":null,"name":"a"} <nil>
+`,
+		},
+		{
+			name: "pattern properties only accept matching names",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				},
+				"patternProperties": {
+					"^x-": { "type": "integer" }
+				}
+			}`,
+			check: func(t *testing.T, g *Generator) {
+				if typ := g.Structs["Root"].Fields["PatternProperties"].MarshalType; typ != "map[string]int" {
+					t.Errorf("expected the pattern properties to be a map[string]int, got %s", typ)
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	fmt.Println(json.Unmarshal([]byte("{\"name\": \"a\", \"x-b\": 2, \"x-a\": 1}"), &r))
+	fmt.Println(r.Name, r.PatternProperties)
+	b, err := json.Marshal(r)
+	fmt.Println(string(b), err)
+	fmt.Println(json.Unmarshal([]byte("{\"y-a\": 1}"), &Root{}))
+	fmt.Println(json.Unmarshal([]byte("{\"x-a\": \"1\"}"), &Root{}) != nil)
+}
+`,
+			expected: `<nil>
+a map[x-a:1 x-b:2]
+{"name":"a","x-a":1,"x-b":2} <nil>
+property "y-a" does not match the pattern "^x-"
+true
+`,
+		},
+		{
+			name: "random values are valid",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string", "minLength": 2, "maxLength": 4 },
+					"color": { "type": "string", "enum": ["red", "green"] },
+					"email": { "type": "string", "format": "email" },
+					"id": { "type": "string", "format": "uuid" },
+					"count": { "type": "integer", "minimum": 10, "maximum": 20, "multipleOf": 3 },
+					"ratio": { "type": "number", "minimum": -1, "maximum": 1, "multipleOf": 0.25 },
+					"level": { "type": "integer", "maximum": 5 },
+					"flag": { "type": "boolean" },
+					"tags": { "type": "array", "items": { "type": "string" } },
+					"child": { "$ref": "#/definitions/child" },
+					"other": { "$ref": "#/definitions/child" }
+				},
+				"required": [ "name", "child" ],
+				"definitions": {
+					"child": {
+						"type": "object",
+						"properties": { "size": { "type": "integer", "minimum": 1, "multipleOf": 2 } },
+						"required": [ "size" ]
+					}
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.OptionalAsPointer = true
+				g.GenerateRandom = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+)
+
+func main() {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		v := Root{}.GenerateRandom(r)
+		if len(v.Name) < 2 || len(v.Name) > 4 || v.Child == nil || v.Other != nil {
+			fmt.Printf("unexpected value %+v\n", v)
+			return
+		}
+		if v.Level != nil && *v.Level > 5 {
+			fmt.Println("level out of range", *v.Level)
+			return
+		}
+		if v.Color != nil && *v.Color != "red" && *v.Color != "green" {
+			fmt.Println("color not in enum", *v.Color)
+			return
+		}
+		if v.Count != nil && (*v.Count < 10 || *v.Count > 20) {
+			fmt.Println("count out of range", *v.Count)
+			return
+		}
+		if v.Ratio != nil && (*v.Ratio < -1 || *v.Ratio > 1) {
+			fmt.Println("ratio out of range", *v.Ratio)
+			return
+		}
+		if v.Child.Size < 1 {
+			fmt.Println("size out of range", v.Child.Size)
+			return
+		}
+		if err := v.Validate(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := json.Unmarshal(b, &Root{}); err != nil {
+			fmt.Println(err, string(b))
+			return
+		}
+	}
+	fmt.Println("ok")
+}
+`,
+			expected: "ok\n",
+		},
+		{
+			name: "required errors use the JSON name of their direction",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"owner": {
+						"type": "object",
+						"properties": { "id": { "type": "integer" } },
+						"marshalKey": "owner_out",
+						"unmarshalKey": "owner-in"
+					}
+				},
+				"required": [ "owner" ]
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	fmt.Println(json.Unmarshal([]byte("{\"owner_out\": {}}"), &r))
+	_, err := r.MarshalJSON()
+	fmt.Println(err)
+}
+`,
+			expected: `"owner-in" is required but was not present
+"owner_out" is a required field
+`,
+		},
+		{
+			name: "snake case keys are marshalled in camel case",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"user_name": { "type": "string" },
+					"id": { "type": "integer" }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.KeyCase = "camel"
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{"{\"user_name\": \"a\", \"id\": 1}", "{\"userName\": \"b\"}"} {
+		var r Root
+		if err := json.Unmarshal([]byte(j), &r); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		b, err := json.Marshal(r)
+		fmt.Println(string(b), err)
+	}
+}
+`,
+			expected: `{"id":1,"userName":"a"} <nil>
+{"id":0,"userName":"b"} <nil>
+`,
+		},
+		{
+			name: "marshal without HybridMarshal",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"count": { "type": "integer" },
+					"tags": { "type": "array", "items": { "type": "string" }, "omitEmpty": true },
+					"dash-key": { "type": "boolean" },
+					"renamed": { "type": "string", "marshalKey": "renamed_out" },
+					"blob": { "type": "string", "contentEncoding": "base64" },
+					"child": { "type": "object", "properties": { "id": { "type": "integer" } }, "omitNull": true }
+				},
+				"required": [ "name" ],
+				"additionalProperties": { "type": "integer" }
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, r := range []Root{
+		{},
+		{Name: "a", Count: 2, Tags: []string{}, DashKey: true, Renamed: "b", Blob: []byte("c"),
+			Child: &Child{Id: 3}, AdditionalProperties: map[string]int{"x": 1}},
+	} {
+		b, err := json.Marshal(r)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		// compare the maps so the order of the keys doesn't matter
+		var m map[string]interface{}
+		fmt.Println(json.Unmarshal(b, &m), m)
+	}
+}
+`,
+			expected: "<nil> map[blob: count:0 dash-key:false name: renamed_out:]\n" +
+				"<nil> map[blob:Yw== child:map[id:3] count:2 dash-key:true name:a renamed_out:b tags:[] x:1]\n",
+		},
+		{
+			name: "HybridMarshal marshals the same as without it",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"count": { "type": "integer" },
+					"tags": { "type": "array", "items": { "type": "string" }, "omitEmpty": true },
+					"dash-key": { "type": "boolean" },
+					"renamed": { "type": "string", "marshalKey": "renamed_out" },
+					"blob": { "type": "string", "contentEncoding": "base64" },
+					"child": { "type": "object", "properties": { "id": { "type": "integer" } }, "omitNull": true }
+				},
+				"required": [ "name" ],
+				"additionalProperties": { "type": "integer" }
+			}`,
+			configure: func(g *Generator) {
+				g.HybridMarshal = true
+			},
+			check: func(t *testing.T, g *Generator) {
+				var buf bytes.Buffer
+				Output(&buf, g, "main")
+				if !strings.Contains(buf.String(), "type alias Root") {
+					t.Errorf("expected the hybrid marshal code, got:\n%s", buf.String())
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, r := range []Root{
+		{},
+		{Name: "a", Count: 2, Tags: []string{}, DashKey: true, Renamed: "b", Blob: []byte("c"),
+			Child: &Child{Id: 3}, AdditionalProperties: map[string]int{"x": 1}},
+	} {
+		b, err := json.Marshal(r)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		// compare the maps so the order of the keys doesn't matter
+		var m map[string]interface{}
+		fmt.Println(json.Unmarshal(b, &m), m)
+	}
+}
+`,
+			expected: "<nil> map[blob: count:0 dash-key:false name: renamed_out:]\n" +
+				"<nil> map[blob:Yw== child:map[id:3] count:2 dash-key:true name:a renamed_out:b tags:[] x:1]\n",
+		},
+		{
+			name: "the property count is validated",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"count": { "type": "integer" }
+				},
+				"additionalProperties": { "type": "string" },
+				"minProperties": 2,
+				"maxProperties": 3
+			}`,
+			configure: func(g *Generator) {
+				g.OptionalAsPointer = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"name\": \"a\"}",
+		"{\"name\": \"a\", \"x\": \"b\"}",
+		"{\"name\": \"a\", \"count\": 0, \"x\": \"b\"}",
+		"{\"name\": \"a\", \"count\": 0, \"x\": \"b\", \"y\": \"c\"}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(j), &r))
+	}
+}
+`,
+			expected: `Root must have at least 2 properties
+<nil>
+<nil>
+Root must have at most 3 properties
+`,
+		},
+		{
+			name: "properties with zero values are counted",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"a": { "type": "integer" },
+					"b": { "type": "string", "omitEmpty": true }
+				},
+				"minProperties": 1,
+				"maxProperties": 1
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{"{\"a\": 0}", "{\"b\": \"\"}", "{}", "{\"a\": 0, \"b\": \"\"}"} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(j), &r))
+	}
+	// Validate can only tell that the omitempty field is set
+	fmt.Println((&Root{B: "x"}).Validate())
+	fmt.Println((&Root{B: ""}).Validate())
+	fmt.Println((&Root{A: 1}).Validate())
+}
+`,
+			expected: `<nil>
+<nil>
+Root must have at least 1 properties
+Root must have at most 1 properties
+<nil>
+Root must have at least 1 properties
+Root must have at least 1 properties
+`,
+		},
+		{
+			name: "empty structs marshal to an empty object",
+			schema: `{
+				"type": "object",
+				"additionalProperties": false
+			}`,
+			check: func(t *testing.T, g *Generator) {
+				var buf bytes.Buffer
+				Output(&buf, g, "test")
+				output := buf.String()
+				for _, imp := range []string{`"strings"`, `"fmt"`} {
+					if strings.Contains(output, imp) {
+						t.Errorf("expected no %s import, got:\n%s", imp, output)
+					}
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, err := json.Marshal(Root{})
+	fmt.Println(string(b), err)
+	var r Root
+	fmt.Println(json.Unmarshal(b, &r))
+	fmt.Println(json.Unmarshal([]byte("[]"), &r) != nil)
+}
+`,
+			expected: "{} <nil>\n<nil>\ntrue\n",
+		},
+		{
+			name: "examples are unmarshalled into structs",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"count": { "type": "integer" }
+				},
+				"required": [ "name" ],
+				"examples": [
+					{ "name": "first", "count": 1 },
+					{ "name": "second" }
+				],
+				"definitions": {
+					"invalid": {
+						"type": "object",
+						"properties": {
+							"name": { "type": "string" }
+						},
+						"required": [ "name" ],
+						"examples": [ { "name": "first" }, {} ]
+					}
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.GenerateExamples = true
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	examples, err := RootExamples()
+	fmt.Println(len(examples), examples[0].Name, examples[0].Count, examples[1].Name, err)
+	// an example which isn't valid is an error rather than a panic
+	invalid, err := InvalidExamples()
+	fmt.Println(invalid, err)
+}
+`,
+			expected: "2 first 1 second <nil>\n[] example 1: \"name\" is required but was not present\n",
+		},
+		{
+			name: "arrays are made with their minimum capacity",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"ids": { "type": "array", "items": { "type": "integer" }, "minItems": 8 },
+					"tags": { "type": "array", "items": { "type": "string" }, "maxItems": 4 },
+					"names": { "type": "array", "items": { "type": "string" } }
+				},
+				"required": [ "ids" ]
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	if err := json.Unmarshal([]byte("{\"ids\": [1, 2], \"tags\": [\"a\"], \"names\": [\"b\"]}"), &r); err != nil {
+		panic(err)
+	}
+	fmt.Println(r.Ids, cap(r.Ids), r.Tags, cap(r.Tags), r.Names, cap(r.Names))
+}
+`,
+			expected: "[1 2] 8 [a] 4 [b] 1\n",
+		},
+		{
+			name: "StrictIntegers rejects fractions",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"count": { "type": "integer" },
+					"ratio": { "type": "integer", "marshalType": "float64", "unmarshalType": "float64" }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.StrictIntegers = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"count\": 1.5}",
+		"{\"ratio\": 1.5}",
+		"{\"count\": 1, \"ratio\": 2.0}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(j), &r))
+	}
+}
+`,
+			expected: `"count" must be an integer, got 1.5
+"ratio" must be an integer, got 1.5
+<nil>
+`,
+		},
+		{
+			name: "SQL values round trip",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"count": { "type": "integer" }
+				},
+				"required": [ "name" ]
+			}`,
+			configure: func(g *Generator) {
+				g.GenerateSQLValuer = true
+			},
+			main: `package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+var (
+	_ sql.Scanner   = (*Root)(nil)
+	_ driver.Valuer = Root{}
+)
+
+func main() {
+	v, err := Root{Name: "a", Count: 2}.Value()
+	if err != nil {
+		panic(err)
+	}
+	var fromBytes, fromString Root
+	fmt.Println(fromBytes.Scan(v), fromBytes)
+	fmt.Println(fromString.Scan(string(v.([]byte))), fromString)
+	fmt.Println(fromString.Scan(1))
+}
+`,
+			expected: "<nil> {2 a}\n<nil> {2 a}\ncannot scan a int into a Root\n",
+		},
+		{
+			name: "Diff returns the changed fields",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string", "marshalKey": "full_name" },
+					"count": { "type": "integer" },
+					"tags": { "type": "array", "items": { "type": "string" } },
+					"child": {
+						"type": "object",
+						"properties": {
+							"id": { "type": "integer" }
+						}
+					}
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.GenerateDiff = true
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	a := Root{Name: "a", Count: 1, Tags: []string{"x"}, Child: &Child{Id: 1}}
+	b := Root{Name: "b", Count: 1, Tags: []string{"x"}, Child: &Child{Id: 2}}
+	diff := a.Diff(b)
+	fmt.Println(len(diff), diff["full_name"], diff["child"].(*Child).Id)
+	fmt.Println(a.Diff(a))
+}
+`,
+			expected: "2 b 2\nmap[]\n",
+		},
+		{
+			name: "not values are rejected",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"color": { "type": "string", "not": { "const": "red" } },
+					"size": { "type": "integer", "not": { "enum": [ 0, 13 ] } }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.OptionalAsPointer = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"color\": \"red\"}",
+		"{\"size\": 13}",
+		"{\"color\": \"blue\", \"size\": 12}",
+		"{}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(j), &r))
+	}
+}
+`,
+			expected: `"color" must not be "red"
+"size" must not be 13
+<nil>
+<nil>
+`,
+		},
+		{
+			name: "nested structs round trip through their marshalers",
+			schema: `{
+				"type": "object",
+				"definitions": {
+					"entry": {
+						"type": "object",
+						"properties": {
+							"code": { "type": "integer" },
+							"name": { "type": "string" }
+						},
+						"required": [ "name" ]
+					}
+				},
+				"properties": {
+					"child": { "$ref": "#/definitions/entry" },
+					"list": { "type": "array", "items": { "$ref": "#/definitions/entry" } }
+				},
+				"additionalProperties": { "$ref": "#/definitions/entry" }
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"child\": {\"code\": 1, \"name\": \"a\"}, \"list\": [{\"code\": 2, \"name\": \"b\"}, {\"name\": \"c\"}], \"other\": {\"code\": 3, \"name\": \"d\"}}",
+		"{\"child\": {\"code\": 1}}",
+		"{\"list\": [{\"code\": 2}]}",
+		"{\"other\": {\"code\": 3}}",
+	} {
+		var r Root
+		if err := json.Unmarshal([]byte(j), &r); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Println(r.Child.Code, r.List[0].Code, r.AdditionalProperties["other"].Code)
+		b, err := json.Marshal(r)
+		fmt.Println(string(b), err)
+	}
+}
+`,
+			expected: `1 2 3
+{"child":{"code":1,"name":"a"},"list":[{"code":2,"name":"b"},{"code":0,"name":"c"}],"other":{"code":3,"name":"d"}} <nil>
+field "child": "name" is required but was not present
+field "list": "name" is required but was not present
+"name" is required but was not present
+`,
+		},
+		{
+			name: "TypedErrors name the field and rule",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"count": { "type": "integer", "multipleOf": 5 }
+				},
+				"required": [ "name" ],
+				"additionalProperties": false
+			}`,
+			configure: func(g *Generator) {
+				g.TypedErrors = true
+				g.DisallowUnknownFields = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"count\": 5}",
+		"{\"name\": \"a\", \"count\": 6}",
+		"{\"name\": \"a\", \"other\": 1}",
+		"{\"name\": \"a\", \"count\": 10}",
+	} {
+		var r Root
+		err := json.Unmarshal([]byte(j), &r)
+		var ve *ValidationError
+		if errors.As(err, &ve) {
+			fmt.Printf("%s %s %s\n", ve.Field, ve.Rule, ve)
+			continue
+		}
+		fmt.Println(err)
+	}
+}
+`,
+			expected: `name required "name" is required but was not present
+count multipleOf "count" must be a multiple of 5
+other additionalProperties unknown field "other"
+<nil>
+`,
+		},
+		{
+			name: "CollectErrors returns all the violations",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"count": { "type": "integer", "multipleOf": 5 },
+					"email": { "type": "string", "format": "email" }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.CollectErrors = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"count\": 6, \"email\": \"not an email\"}",
+		"{\"count\": 6}",
+		"{\"count\": 10, \"email\": \"a@example.com\"}",
+	} {
+		var r Root
+		err := json.Unmarshal([]byte(j), &r)
+		if errs, ok := err.(interface{ Unwrap() []error }); ok {
+			fmt.Println(len(errs.Unwrap()), err)
+			continue
+		}
+		fmt.Println(err)
+	}
+}
+`,
+			expected: `2 "count" must be a multiple of 5; "email" is not a valid email
+1 "count" must be a multiple of 5
+<nil>
+`,
+		},
+		{
+			name: "dependent required properties are checked",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"credit_card": { "type": "string" },
+					"billing_address": { "type": "string" }
+				},
+				"dependentRequired": {
+					"credit_card": [ "billing_address" ]
+				}
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"name\": \"a\"}",
+		"{\"name\": \"a\", \"credit_card\": \"1234\"}",
+		"{\"name\": \"a\", \"credit_card\": \"1234\", \"billing_address\": \"b\"}",
+		"{\"name\": \"a\", \"billing_address\": \"b\"}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(j), &r))
+	}
+}
+`,
+			expected: `<nil>
+"billing_address" is required when "credit_card" is present
+<nil>
+<nil>
+`,
+		},
+		{
+			name: "Validate checks the required properties of set fields",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"type": { "type": "string" },
+					"y": { "type": "string" },
+					"credit_card": { "type": "string" },
+					"billing_address": { "type": "string" },
+					"plain": { "type": "string" }
+				},
+				"required": [ "plain" ],
+				"if": { "properties": { "type": { "const": "X" } }, "required": [ "type" ] },
+				"then": { "required": [ "y" ] },
+				"dependentRequired": {
+					"credit_card": [ "billing_address", "plain" ]
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.OptionalAsPointer = true
+			},
+			check: func(t *testing.T, g *Generator) {
+				var buf bytes.Buffer
+				Output(&buf, g, "main")
+				if !strings.Contains(buf.String(), "return strct.validate(false)") {
+					t.Errorf("expected UnmarshalJSON to leave the checks of the JSON out of Validate, got:\n%s", buf.String())
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	x, card, address := "X", "1234", "b"
+	fmt.Println((&Root{Type: &x}).Validate())
+	fmt.Println((&Root{Type: &x, Y: &address}).Validate())
+	fmt.Println((&Root{CreditCard: &card}).Validate())
+	// whether the required plain field is set can't be told, so it isn't checked
+	fmt.Println((&Root{CreditCard: &card, BillingAddress: &address}).Validate())
+	// a null value leaves the pointer nil, but the property is present in the JSON
+	var r Root
+	fmt.Println(json.Unmarshal([]byte("{\"credit_card\": \"1234\", \"billing_address\": null, \"plain\": \"\"}"), &r))
+}
+`,
+			expected: `"y" is required when "type" is "X"
+<nil>
+"billing_address" is required when "credit_card" is present
+<nil>
+<nil>
+`,
+		},
+		{
+			name: "Canonical marshal sorts all the keys",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"b": { "type": "integer" },
+					"d": {
+						"type": "object",
+						"properties": {
+							"z_last": { "type": "string" },
+							"zFirst": { "type": "string" }
+						}
+					}
+				},
+				"required": [ "b" ],
+				"additionalProperties": { "type": "integer" }
+			}`,
+			configure: func(g *Generator) {
+				g.Canonical = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	r := Root{
+		B: 2,
+		D: &D{ZLast: "y", ZFirst: "x"},
+		AdditionalProperties: map[string]int{"e": 5, "a": 1, "c": 3},
+	}
+	b, err := json.Marshal(r)
+	fmt.Println(string(b), err)
+}
+`,
+			expected: `{"a":1,"b":2,"c":3,"d":{"zFirst":"x","z_last":"y"},"e":5} <nil>
+`,
+		},
+		{
+			// U+1F600 comes before U+FF61 by its UTF-16 code units, but after it by its UTF-8 bytes
+			name: "Canonical marshal writes the JSON canonicalization scheme",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"text": { "type": "string" },
+					"ratio": { "type": "number" }
+				},
+				"additionalProperties": { "type": "number" }
+			}`,
+			configure: func(g *Generator) {
+				g.Canonical = true
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	r := Root{
+		Text:                 "<&>\"\\\n\u0001\u2028",
+		Ratio:                0.000001,
+		AdditionalProperties: map[string]float64{"\uff61": 1e21, "\U0001F600": 1e-7, "big": 123456789012, "neg": -1.5},
+	}
+	b, err := r.MarshalJSON()
+	fmt.Println(string(b), err)
+}
+`,
+			expected: `{"big":123456789012,"neg":-1.5,"ratio":0.000001,"text":"<&>\"\\\n\u0001` + "\u2028" + `","` + "\U0001F600" + `":1e-7,"` + "\uff61" + `":1e+21} <nil>
+`,
+		},
+		{
+			name: "NoAdditionalProperties leaves out the additional properties code",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"tags": {
+						"type": "object",
+						"properties": { "color": { "type": "string" } },
+						"additionalProperties": true
+					}
+				},
+				"additionalProperties": { "type": "integer" }
+			}`,
+			configure: func(g *Generator) {
+				g.NoAdditionalProperties = true
+			},
+			check: func(t *testing.T, g *Generator) {
+				var buf bytes.Buffer
+				Output(&buf, g, "main")
+				if strings.Contains(buf.String(), "AdditionalProperties") {
+					t.Errorf("expected no additional properties code, got:\n%s", buf.String())
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	err := json.Unmarshal([]byte("{\"name\": \"a\", \"count\": 1, \"tags\": {\"color\": \"red\", \"size\": 2}}"), &r)
+	fmt.Println(err)
+	b, err := json.Marshal(r)
+	fmt.Println(string(b), err)
+}
+`,
+			expected: `<nil>
+{"name":"a","tags":{"color":"red"}} <nil>
+`,
+		},
+		{
+			name: "conversion errors name the field",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"age": { "type": "string", "marshalType": "int", "unmarshalType": "string" },
+					"count": { "type": "integer", "marshalType": "string", "unmarshalType": "int" },
+					"name": { "type": "string" }
+				}
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"age\": \"42\", \"count\": 3, \"name\": \"a\"}",
+		"{\"age\": \"forty\"}",
+		"{\"count\": \"3\"}",
+		"{\"name\": 1}",
+	} {
+		var r Root
+		err := json.Unmarshal([]byte(j), &r)
+		fmt.Println(r.Age, r.Count, err)
+	}
+}
+`,
+			expected: `42 3 <nil>
+0  field "age": strconv.Atoi: parsing "forty": invalid syntax
+0  field "count": json: cannot unmarshal string into Go value of type int
+0  field "name": json: cannot unmarshal number into Go value of type string
+`,
+		},
+		{
+			name: "array roots are usable slice types",
+			schema: `{
+				"title": "ItemList",
+				"type": "array",
+				"minItems": 1,
+				"maxItems": 2,
+				"items": {
+					"title": "Item",
+					"type": "object",
+					"properties": { "x": { "type": "integer" } },
+					"required": [ "x" ]
+				}
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"[{\"x\": 1}, {\"x\": 2}]",
+		"[]",
+		"[{\"x\": 1}, {\"x\": 2}, {\"x\": 3}]",
+		"[{\"y\": 1}]",
+	} {
+		var list ItemList
+		err := json.Unmarshal([]byte(j), &list)
+		fmt.Println(len(list), err)
+	}
+	list := ItemList{{X: 1}}
+	list = append(list, &Item{X: 2})
+	b, err := json.Marshal(list)
+	fmt.Println(string(b), err, list[1].X)
+	_, err = json.Marshal(ItemList{})
+	fmt.Println(err != nil, ItemList{}.Validate())
+}
+`,
+			expected: `2 <nil>
+0 ItemList must have at least 1 items
+0 ItemList must have at most 2 items
+0 "x" is required but was not present
+[{"x":1},{"x":2}] <nil> 2
+true ItemList must have at least 1 items
+`,
+		},
+		{
+			name: "the items of array roots are checked against their enum",
+			schema: `{
+				"title": "Colors",
+				"type": "array",
+				"items": { "type": "string", "enum": [ "red", "green" ] }
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{"[\"red\", \"green\"]", "[\"red\", \"blue\"]"} {
+		var colors Colors
+		fmt.Println(json.Unmarshal([]byte(j), &colors))
+	}
+}
+`,
+			expected: `<nil>
+item 1 of Colors is not an allowed value
+`,
+		},
+		{
+			name: "array roots return ValidationErrors with TypedErrors",
+			schema: `{
+				"title": "Names",
+				"type": "array",
+				"minItems": 1,
+				"items": { "type": "string" }
+			}`,
+			configure: func(g *Generator) {
+				g.TypedErrors = true
+			},
+			check: func(t *testing.T, g *Generator) {
+				if len(g.Structs) != 0 {
+					t.Fatalf("expected no structs, got %v", getOrderedStructNames(g.Structs))
+				}
+			},
+			main: `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func main() {
+	var err *ValidationError
+	fmt.Println(errors.As(Names{}.Validate(), &err), err.Rule)
+}
+`,
+			expected: "true minItems\n",
+		},
+		{
+			name: "single items are coerced into arrays",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"items": {
+						"type": "array",
+						"items": {
+							"type": "object",
+							"properties": { "x": { "type": "integer" } }
+						}
+					},
+					"tags": { "type": "array", "items": { "type": "string" } }
+				},
+				"required": [ "items" ]
+			}`,
+			configure: func(g *Generator) {
+				g.CoerceSingletonArrays = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"items\": {\"x\": 1}, \"tags\": \"a\"}",
+		"{\"items\": [{\"x\": 1}], \"tags\": [\"a\", \"b\"]}",
+		"{\"items\": null}",
+	} {
+		var r Root
+		err := json.Unmarshal([]byte(j), &r)
+		fmt.Println(len(r.Items), len(r.Tags), err)
+		if len(r.Items) > 0 {
+			fmt.Println(r.Items[0].X, r.Tags[0])
+		}
+	}
+}
+`,
+			expected: `1 1 <nil>
+1 a
+1 2 <nil>
+1 a
+0 0 <nil>
+`,
+		},
+		{
+			name: "nil slices marshal as empty arrays with EmptySliceNotNull",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"tags": { "type": "array", "items": { "type": "string" } }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.EmptySliceNotNull = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, err := json.Marshal(Root{Name: "a"})
+	fmt.Println(string(b), err)
+	b, err = json.Marshal(Root{Name: "a", Tags: []string{"b"}})
+	fmt.Println(string(b), err)
+}
+`,
+			expected: "{\"name\":\"a\",\"tags\":[]} <nil>\n{\"name\":\"a\",\"tags\":[\"b\"]} <nil>\n",
+		},
+		{
+			name: "nil slices marshal as null without EmptySliceNotNull",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"tags": { "type": "array", "items": { "type": "string" } }
+				}
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, err := json.Marshal(Root{Name: "a"})
+	fmt.Println(string(b), err)
+	b, err = json.Marshal(Root{Name: "a", Tags: []string{"b"}})
+	fmt.Println(string(b), err)
+}
+`,
+			expected: "{\"name\":\"a\",\"tags\":null} <nil>\n{\"name\":\"a\",\"tags\":[\"b\"]} <nil>\n",
+		},
+		{
+			name: "additional properties are capped by maxProperties",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"age": { "type": "integer" }
+				},
+				"maxProperties": 4,
+				"additionalProperties": { "type": "string" }
+			}`,
+			configure: func(g *Generator) {
+				g.CapAdditionalProperties = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"name\": \"a\", \"b\": \"b\", \"c\": \"c\"}",
+		"{\"b\": \"b\", \"c\": \"c\", \"d\": \"d\"}",
+		"{\"name\": \"a\", \"age\": 1, \"b\": \"b\", \"c\": \"c\", \"d\": \"d\"}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(j), &r))
+	}
+}
+`,
+			expected: `<nil>
+Root must have at most 2 additional properties
+Root must have at most 2 additional properties
+`,
+		},
+		{
+			name: "boolean schemas accept any or no value",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"anything": true,
+					"nothing": false
+				}
+			}`,
+			check: func(t *testing.T, g *Generator) {
+				if typ := g.Structs["Root"].Fields["Anything"].MarshalType; typ != "interface{}" {
+					t.Errorf("expected the field of the true schema to be interface{}, got %q", typ)
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"anything\": {\"a\": [1, \"b\", null]}}",
+		"{\"anything\": 1.5}",
+		"{\"nothing\": null}",
+		"{\"nothing\": {}}",
+	} {
+		var r Root
+		err := json.Unmarshal([]byte(j), &r)
+		b, _ := json.Marshal(r)
+		fmt.Println(string(b), err)
+	}
+}
+`,
+			expected: `{"anything":{"a":[1,"b",null]}} <nil>
+{"anything":1.5} <nil>
+{"anything":null} "nothing" is not allowed
+{"anything":null} "nothing" is not allowed
+`,
+		},
+		{
+			name: "pooled buffers are copied before they are reused",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"size": { "type": "integer" }
+				},
+				"required": [ "name" ]
+			}`,
+			configure: func(g *Generator) {
+				g.PooledBuffers = true
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	// the bytes of the first call are kept while the buffer is used again
+	first, err := Root{Name: "first", Size: 1}.MarshalJSON()
+	if err != nil {
+		panic(err)
+	}
+	second, err := Root{Name: "other", Size: 2}.MarshalJSON()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(first), string(second))
+}
+`,
+			expected: `{"name": "first", "size": 1} {"name": "other", "size": 2}` + "\n",
+		},
+		{
+			name: "enum parsers reject values outside the enum",
+			schema: `{
+				"title": "Color",
+				"type": "string",
+				"enum": [ "red", "green" ]
+			}`,
+			configure: func(g *Generator) {
+				g.GenerateEnumParsers = true
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(ParseColor("green"))
+	fmt.Println(ParseColor("purple"))
+	fmt.Println(Color("red").Valid(), Color("").Valid())
+}
+`,
+			expected: `green <nil>
+ "purple" is not a valid Color
+true false
+`,
+		},
+		{
+			// a const is parsed like an enum of one value
+			name: "enum parsers accept only the const",
+			schema: `{
+				"title": "Shape",
+				"type": "string",
+				"const": "circle"
+			}`,
+			configure: func(g *Generator) {
+				g.GenerateEnumParsers = true
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(Shape("circle").Valid(), Shape("square").Valid())
+}
+`,
+			expected: "true false\n",
+		},
+		{
+			name: "OptimizeFieldAlignment orders fields from largest to smallest",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"active": { "type": "boolean" },
+					"count": { "type": "integer" },
+					"enabled": { "type": "boolean" },
+					"name": { "type": "string" },
+					"ratio": { "type": "number" },
+					"tags": { "type": "array", "items": { "type": "string" } }
+				},
+				"required": [ "name" ]
+			}`,
+			configure: func(g *Generator) {
+				g.OptimizeFieldAlignment = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+func main() {
+	typ := reflect.TypeOf(Root{})
+	for i := 0; i < typ.NumField(); i++ {
+		fmt.Print(typ.Field(i).Name, " ")
+	}
+	fmt.Println(unsafe.Sizeof(Root{}))
+
+	data := "{\"active\": true, \"count\": 2, \"enabled\": false, \"name\": \"a\", \"ratio\": 0.5, \"tags\": [\"b\"]}"
+	var r Root
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		panic(err)
+	}
+	b, err := json.Marshal(r)
+	fmt.Println(string(b), err)
+}
+`,
+			expected: `Tags Name Count Ratio Active Enabled 64
+{"active":true,"count":2,"enabled":false,"name":"a","ratio":0.5,"tags":["b"]} <nil>
+`,
+		},
+		{
+			name: "ISO 8601 durations are time durations",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"timeout": { "type": "string", "format": "duration" },
+					"interval": { "type": "string", "format": "duration" }
+				},
+				"required": [ "timeout" ]
+			}`,
+			configure: func(g *Generator) {
+				g.DurationFormat = "iso8601"
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"timeout\": \"P1DT2H30M\", \"interval\": \"-PT1.5S\"}",
+		"{\"timeout\": \"soon\"}",
+	} {
+		var r Root
+		err := json.Unmarshal([]byte(j), &r)
+		fmt.Println(r.Timeout == 26*time.Hour+30*time.Minute, r.Interval == -1500*time.Millisecond, err)
+		if err == nil {
+			b, err := json.Marshal(r)
+			fmt.Println(string(b), err)
+		}
+	}
+}
+`,
+			expected: `true true <nil>
+{"interval":"-PT1.5S","timeout":"PT26H30M"} <nil>
+false false field "timeout": invalid ISO 8601 duration "soon"
+`,
+		},
+		{
+			name: "Go durations are time durations",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"timeout": { "type": "string", "format": "duration" },
+					"interval": { "type": "string", "format": "duration" }
+				},
+				"required": [ "timeout" ]
+			}`,
+			configure: func(g *Generator) {
+				g.DurationFormat = "go"
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"timeout\": \"26h30m\", \"interval\": \"-1.5s\"}",
+		"{\"timeout\": \"soon\"}",
+	} {
+		var r Root
+		err := json.Unmarshal([]byte(j), &r)
+		fmt.Println(r.Timeout == 26*time.Hour+30*time.Minute, r.Interval == -1500*time.Millisecond, err)
+		if err == nil {
+			b, err := json.Marshal(r)
+			fmt.Println(string(b), err)
+		}
+	}
+}
+`,
+			expected: `true true <nil>
+{"interval":"-1.5s","timeout":"26h30m0s"} <nil>
+false false field "timeout": time: invalid duration "soon"
+`,
+		},
+		{
+			name: "NumbersAsStrings quotes numbers",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"count": { "type": "integer" },
+					"ratio": { "type": "number" }
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.NumbersAsStrings = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, err := json.Marshal(Root{Count: 42, Ratio: 0.25})
+	fmt.Println(string(b), err)
+	for _, j := range []string{
+		"{\"count\": \"42\", \"ratio\": \"1e3\"}",
+		"{\"count\": 42}",
+		"{\"count\": \"4.2\"}",
+	} {
+		var r Root
+		err := json.Unmarshal([]byte(j), &r)
+		fmt.Println(r.Count, r.Ratio, err)
+	}
+}
+`,
+			expected: `{"count":"42","ratio":"0.25"} <nil>
+42 1000 <nil>
+0 0 field "count": json: cannot unmarshal number into Go value of type string
+0 0 field "count": strconv.Atoi: parsing "4.2": invalid syntax
+`,
+		},
+		{
+			name: "a struct with every field omitted marshals as an empty object",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"count": { "type": "integer", "omitEmpty": true },
+					"name": { "type": "string", "omitEmpty": true },
+					"tags": { "type": "array", "items": { "type": "string" }, "omitEmpty": true }
+				},
+				"additionalProperties": { "type": "string" }
+			}`,
+			check: func(t *testing.T, g *Generator) {
+				if !g.Structs["Root"].GenerateCode {
+					t.Fatal("expected the struct to have a generated MarshalJSON")
+				}
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	b, err := Root{}.MarshalJSON()
+	fmt.Printf("%s %v\n", b, err)
+	b, err = Root{AdditionalProperties: map[string]string{}}.MarshalJSON()
+	fmt.Printf("%s %v\n", b, err)
+}
+`,
+			expected: "{} <nil>\n{} <nil>\n",
+		},
+		{
+			name: "envelopes are unmarshalled into the struct of their type",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"created": { "$ref": "#/definitions/Created" },
+					"deleted": { "$ref": "#/definitions/Deleted" }
+				},
+				"definitions": {
+					"Created": {
+						"type": "object",
+						"properties": {
+							"kind": { "type": "string", "const": "created" },
+							"name": { "type": "string" }
+						}
+					},
+					"Deleted": {
+						"type": "object",
+						"properties": {
+							"kind": { "type": "string", "const": "deleted" },
+							"id": { "type": "integer" }
+						}
+					}
+				}
+			}`,
+			configure: func(g *Generator) {
+				g.EnvelopeDiscriminator = "kind"
+			},
+			main: `package main
+
+import "fmt"
+
+func main() {
+	for _, j := range []string{
+		"{\"type\": \"created\", \"data\": {\"kind\": \"created\", \"name\": \"a\"}}",
+		"{\"type\": \"deleted\", \"data\": {\"kind\": \"deleted\", \"id\": 2}}",
+		"{\"type\": \"updated\", \"data\": {}}",
+	} {
+		v, err := UnmarshalEnvelope([]byte(j))
+		switch v := v.(type) {
+		case *Created:
+			fmt.Println("created", v.Name)
+		case *Deleted:
+			fmt.Println("deleted", v.Id)
+		default:
+			fmt.Println(v, err)
+		}
+	}
+}
+`,
+			expected: `created a
+deleted 2
+<nil> unknown envelope type "updated"
+`,
+		},
+		{
+			name: "nil additional properties leave no dangling comma",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"size": { "type": "integer" }
+				},
+				"additionalProperties": { "type": "integer" }
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, err := Root{Name: "a", Size: 1}.MarshalJSON()
+	fmt.Printf("%s %v %v\n", b, json.Valid(b), err)
+}
+`,
+			expected: `{"name": "a", "size": 1} true <nil>` + "\n",
+		},
+		{
+			// the pooled buffers join the lines themselves rather than with strings.Join
+			name: "nil additional properties leave no dangling comma with pooled buffers",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"size": { "type": "integer" }
+				},
+				"additionalProperties": { "type": "integer" }
+			}`,
+			configure: func(g *Generator) {
+				g.PooledBuffers = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, err := Root{Name: "a", Size: 1}.MarshalJSON()
+	fmt.Printf("%s %v %v\n", b, json.Valid(b), err)
+}
+`,
+			expected: `{"name": "a", "size": 1} true <nil>` + "\n",
+		},
+		{
+			name: "ordered additional properties keep their order",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				},
+				"required": [ "name" ],
+				"additionalProperties": { "type": "integer" }
+			}`,
+			configure: func(g *Generator) {
+				g.OrderedAdditional = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	if err := json.Unmarshal([]byte("{\"zebra\": 1, \"name\": \"a\", \"apple\": 2, \"mango\": 3}"), &r); err != nil {
+		panic(err)
+	}
+	r.SetAdditional("banana", 4)
+	r.AdditionalProperties["cherry"] = 5
+	b, err := r.MarshalJSON()
+	fmt.Println(string(b), err)
+
+	var again Root
+	fmt.Println(json.Unmarshal(b, &again), again.AdditionalOrder)
+}
+`,
+			expected: `{"name": "a", "zebra": 1, "apple": 2, "mango": 3, "banana": 4, "cherry": 5} <nil>
+<nil> [zebra apple mango banana cherry]
+`,
+		},
+		{
+			// the keys are decoded in order by the fast unmarshalling and from the map of the properties otherwise
+			name: "ordered additional properties keep their order with FastUnmarshal",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				},
+				"required": [ "name" ],
+				"additionalProperties": { "type": "integer" }
+			}`,
+			configure: func(g *Generator) {
+				g.OrderedAdditional = true
+				g.FastUnmarshal = true
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	if err := json.Unmarshal([]byte("{\"zebra\": 1, \"name\": \"a\", \"apple\": 2, \"mango\": 3}"), &r); err != nil {
+		panic(err)
+	}
+	r.SetAdditional("banana", 4)
+	r.AdditionalProperties["cherry"] = 5
+	b, err := r.MarshalJSON()
+	fmt.Println(string(b), err)
+
+	var again Root
+	fmt.Println(json.Unmarshal(b, &again), again.AdditionalOrder)
+}
+`,
+			expected: `{"name": "a", "zebra": 1, "apple": 2, "mango": 3, "banana": 4, "cherry": 5} <nil>
+<nil> [zebra apple mango banana cherry]
+`,
+		},
+		{
+			name: "arrays have to contain matching items",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"roles": {
+						"type": "array",
+						"items": { "type": "string" },
+						"contains": { "const": "admin" }
+					},
+					"scores": {
+						"type": "array",
+						"items": { "type": "integer" },
+						"contains": { "minimum": 90 },
+						"minContains": 2,
+						"maxContains": 3
+					}
+				}
+			}`,
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"roles\": [\"user\", \"admin\"], \"scores\": [95, 10, 90]}",
+		"{\"roles\": [\"user\"]}",
+		"{\"scores\": [95, 10]}",
+		"{\"scores\": [95, 91, 92, 93]}",
+		"{}",
+	} {
+		var r Root
+		fmt.Println(json.Unmarshal([]byte(j), &r))
+	}
+}
+`,
+			expected: `<nil>
+"roles" must contain at least 1 matching items
+"scores" must contain at least 2 matching items
+"scores" must contain at most 3 matching items
+<nil>
+`,
+		},
+		{
+			name: "float specials are marshalled as errors",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"ratio": { "type": "number" }
+				},
+				"required": [ "name" ]
+			}`,
+			configure: func(g *Generator) {
+				g.FloatSpecials = "error"
+			},
+			main: `package main
+
+import (
+	"fmt"
+	"math"
+)
+
+func main() {
+	for _, ratio := range []float64{math.Inf(1), math.NaN(), 0.5} {
+		b, err := Root{Name: "a", Ratio: ratio}.MarshalJSON()
+		fmt.Println(string(b), err)
+	}
+}
+`,
+			expected: " json: unsupported value: +Inf\n json: unsupported value: NaN\n{\"name\": \"a\", \"ratio\": 0.5} <nil>\n",
+		},
+		{
+			name: "float specials are marshalled as null",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"ratio": { "type": "number" }
+				},
+				"required": [ "name" ]
+			}`,
+			configure: func(g *Generator) {
+				g.FloatSpecials = "null"
+			},
+			main: `package main
+
+import (
+	"fmt"
+	"math"
+)
+
+func main() {
+	for _, ratio := range []float64{math.Inf(1), math.NaN(), 0.5} {
+		b, err := Root{Name: "a", Ratio: ratio}.MarshalJSON()
+		fmt.Println(string(b), err)
+	}
+}
+`,
+			expected: "{\"name\": \"a\", \"ratio\": null} <nil>\n{\"name\": \"a\", \"ratio\": null} <nil>\n{\"name\": \"a\", \"ratio\": 0.5} <nil>\n",
+		},
+		{
+			name: "float specials are marshalled as strings",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"ratio": { "type": "number" }
+				},
+				"required": [ "name" ]
+			}`,
+			configure: func(g *Generator) {
+				g.FloatSpecials = "string"
+			},
+			main: `package main
+
+import (
+	"fmt"
+	"math"
+)
+
+func main() {
+	for _, ratio := range []float64{math.Inf(1), math.NaN(), 0.5} {
+		b, err := Root{Name: "a", Ratio: ratio}.MarshalJSON()
+		fmt.Println(string(b), err)
+	}
+}
+`,
+			expected: "{\"name\": \"a\", \"ratio\": \"+Inf\"} <nil>\n{\"name\": \"a\", \"ratio\": \"NaN\"} <nil>\n{\"name\": \"a\", \"ratio\": 0.5} <nil>\n",
+		},
+		{
+			name: "StrictRequiredMarshal rejects zero values",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"count": { "type": "integer" },
+					"child": {
+						"type": "object",
+						"properties": { "size": { "type": "integer" } }
+					},
+					"created": { "type": "string", "format": "date-time" }
+				},
+				"required": [ "name", "count", "child", "created" ]
+			}`,
+			configure: func(g *Generator) {
+				g.StrictRequiredMarshal = true
+				// a struct which isn't a pointer has no literal zero value
+				g.TypeMapper = func(schemaType, format string) (string, string, bool) {
+					return "time.Time", "time", format == "date-time"
+				}
+			},
+			main: `package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, r := range []Root{
+		{Name: "a", Count: 1, Child: &Child{}, Created: created},
+		{Count: 1, Child: &Child{}, Created: created},
+		{Name: "a", Child: &Child{}, Created: created},
+		{Name: "a", Count: 1, Created: created},
+		{Name: "a", Count: 1, Child: &Child{}},
+	} {
+		b, err := r.MarshalJSON()
+		fmt.Println(string(b), err)
+	}
+}
+`,
+			expected: `{"child": {"size":0}, "count": 1, "created": "2020-01-02T03:04:05Z", "name": "a"} <nil>
+ "name" is a required field
+ "count" is a required field
+ "child" is a required field
+ "created" is a required field
+`,
+		},
+		{
+			name: "TypeMapper packages are named after the qualifiers of their types",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"small": { "type": "integer" },
+					"large": { "type": "number" },
+					"reason": { "type": "string" }
+				},
+				"required": [ "small" ]
+			}`,
+			configure: func(g *Generator) {
+				g.TypeMapper = func(schemaType, format string) (string, string, bool) {
+					switch schemaType {
+					case "integer":
+						return "*big.Int", "generated/a/big/v2", true
+					case "number":
+						return "*big.Float", "generated/b/big/v2", true
+					case "string":
+						// clashes with the errors package of the required check
+						return "errors.Reason", "generated/errors", true
+					}
+					return "", "", false
+				}
+			},
+			check: func(t *testing.T, g *Generator) {
+				var buf bytes.Buffer
+				Output(&buf, g, "main")
+				output := buf.String()
+				for _, expected := range []string{
+					"\t\"errors\"\n",
+					"\tgeneratederrors \"generated/errors\"\n",
+					"\tabig \"generated/a/big/v2\"\n",
+					"\t\"generated/b/big/v2\"\n",
+					"Large *big.Float `json:\"large\"`",
+					"Reason generatederrors.Reason `json:\"reason\"`",
+					"Small *abig.Int `json:\"small\"`",
+				} {
+					if !strings.Contains(output, expected) {
+						t.Errorf("expected the output to contain %q, got:\n%s", expected, output)
+					}
+				}
+			},
+			main: `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var r Root
+	err := json.Unmarshal([]byte(` + "`" + `{"small": {"V": 1}, "large": {"V": 2.5}, "reason": "a"}` + "`" + `), &r)
+	fmt.Println(r.Small.V, r.Large.V, r.Reason, err)
+	fmt.Println(json.Unmarshal([]byte("{}"), &r))
+}
+`,
+			expected: "1 2.5 a <nil>\n\"small\" is required but was not present\n",
+			files: map[string]string{
+				"a/big/v2/big.go":  "package big\n\ntype Int struct{ V int }\n",
+				"b/big/v2/big.go":  "package big\n\ntype Float struct{ V float64 }\n",
+				"errors/errors.go": "package errors\n\ntype Reason string\n",
+			},
+		},
+	})
+}
+
 func TestThatFieldNamesAreOrdered(t *testing.T) {
 	m := map[string]Field{
 		"z": {},
 		"b": {},
 	}
 
-	actual := getOrderedFieldNames(m)
-	expected := []string{"b", "z"}
+	actual := getOrderedFieldNames(m)
+	expected := []string{"b", "z"}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %s and actual %s should match in order", strings.Join(expected, ", "), strings.Join(actual, ","))
+	}
+}
+
+func TestThatStructNamesAreOrdered(t *testing.T) {
+	m := map[string]Struct{
+		"c": {},
+		"b": {},
+		"a": {},
+	}
+
+	actual := getOrderedStructNames(m)
+	expected := []string{"a", "b", "c"}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %s and actual %s should match in order", strings.Join(expected, ", "), strings.Join(actual, ","))
+	}
+}
+
+func TestLineAndCharacterFromOffset(t *testing.T) {
+	tests := []struct {
+		In                []byte
+		Offset            int
+		ExpectedLine      int
+		ExpectedCharacter int
+		ExpectedError     bool
+	}{
+		{
+			In:                []byte("Line 1\nLine 2"),
+			Offset:            6,
+			ExpectedLine:      2,
+			ExpectedCharacter: 1,
+		},
+		{
+			In:                []byte("Line 1\r\nLine 2"),
+			Offset:            7,
+			ExpectedLine:      2,
+			ExpectedCharacter: 1,
+		},
+		{
+			In:                []byte("Line 1\nLine 2"),
+			Offset:            0,
+			ExpectedLine:      1,
+			ExpectedCharacter: 1,
+		},
+		{
+			In:                []byte("Line 1\nLine 2"),
+			Offset:            200,
+			ExpectedLine:      0,
+			ExpectedCharacter: 0,
+			ExpectedError:     true,
+		},
+		{
+			In:                []byte("Line 1\nLine 2"),
+			Offset:            -1,
+			ExpectedLine:      0,
+			ExpectedCharacter: 0,
+			ExpectedError:     true,
+		},
+	}
+
+	for _, test := range tests {
+		actualLine, actualCharacter, err := lineAndCharacter(test.In, test.Offset)
+		if err != nil && !test.ExpectedError {
+			t.Errorf("Unexpected error for input %s at offset %d: %v", test.In, test.Offset, err)
+			continue
+		}
+
+		if actualLine != test.ExpectedLine || actualCharacter != test.ExpectedCharacter {
+			t.Errorf("For '%s' at offset %d, expected %d:%d, but got %d:%d", test.In, test.Offset, test.ExpectedLine, test.ExpectedCharacter, actualLine, actualCharacter)
+		}
+	}
+}
+
+func TestThatMinimalMethodsSkipsMarshalCodeForPlainStructs(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"definitions": {
+			"plain": {
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" }
+				}
+			},
+			"converted": {
+				"type": "object",
+				"properties": {
+					"count": { "type": "integer", "marshalType": "string" }
+				}
+			}
+		}
+	}`, func(g *Generator) {
+		// optional pointers need the marshal code unless MinimalMethods recognises the struct tags are enough
+		g.OptionalAsPointer = true
+		g.MinimalMethods = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	code := buf.String()
+
+	if strings.Contains(code, "func (strct Plain) MarshalJSON()") || strings.Contains(code, "func (strct *Plain) UnmarshalJSON(") {
+		t.Errorf("expected no custom marshal code for the plain struct, got:\n%s", code)
+	}
+	if !strings.Contains(code, "Name *string `json:\"name,omitempty\"`") {
+		t.Errorf("expected the plain struct to rely on its struct tags, got:\n%s", code)
+	}
+	if !strings.Contains(code, "func (strct Converted) MarshalJSON()") || !strings.Contains(code, "func (strct *Converted) UnmarshalJSON(") {
+		t.Errorf("expected custom marshal code for the struct with a conversion, got:\n%s", code)
+	}
+}
+
+func TestThatMarshalJSONIndentProducesIndentedJSON(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"child": {
+				"type": "object",
+				"properties": {
+					"id": { "type": "integer" }
+				},
+				"required": [ "id" ]
+			}
+		},
+		"required": [ "name" ]
+	}`, func(g *Generator) {
+		g.GenerateMarshalIndent = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	// json.MarshalIndent re-indents what MarshalJSON returns, so the generated marshal code is used
+	expected := "func (strct Root) MarshalJSONIndent(prefix, indent string) ([]byte, error) {\n" +
+		"\treturn json.MarshalIndent(strct, prefix, indent)\n}\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected the output to contain %q, got:\n%s", expected, buf.String())
+	}
+}
+
+func TestThatAdditionalPropertiesCanBeAccessedWithTypedHelpers(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" }
+		},
+		"additionalProperties": { "type": "integer" }
+	}`, nil)
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	output := buf.String()
+	for _, expected := range []string{
+		"func (strct *Root) GetAdditional(key string) (int, bool) {\n",
+		"func (strct *Root) SetAdditional(key string, v int) {\n",
+		// a zero Root can be set without making the map first
+		"\tif strct.AdditionalProperties == nil {\n\t\tstrct.AdditionalProperties = make(map[string]int)\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestThatToMapGenerationCanBeSuppressed(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" }
+		},
+		"required": [ "name" ]
+	}`
+	tests := []struct {
+		generateToMap bool
+		expected      bool
+	}{
+		{generateToMap: true, expected: true},
+		{generateToMap: false, expected: false},
+	}
+
+	for _, test := range tests {
+		g := generateFromJSON(t, schema, func(g *Generator) {
+			g.GenerateToMap = test.generateToMap
+		})
+		var buf bytes.Buffer
+		Output(&buf, g, "main")
+		if actual := strings.Contains(buf.String(), ") ToMap() map[string]any"); actual != test.expected {
+			t.Errorf("with GenerateToMap %v expected the ToMap method to be present %v, but got %v", test.generateToMap, test.expected, actual)
+		}
+	}
+}
+
+func TestThatValidateTagsAreMappedFromConstraints(t *testing.T) {
+	one, ten := 1.0, 10.0
+	two, five := 2, 5
+	g := &Generator{EmitValidateTags: true}
+
+	tests := []struct {
+		name     string
+		field    Field
+		expected string
+	}{
+		{
+			name:     "no constraints",
+			field:    Field{UnmarshalName: "a"},
+			expected: "`json:\"a\"`",
+		},
+		{
+			name:     "required",
+			field:    Field{UnmarshalName: "a", Required: true},
+			expected: "`json:\"a\" validate:\"required\"`",
+		},
+		{
+			name:     "minimum and maximum",
+			field:    Field{UnmarshalName: "a", Minimum: &one, Maximum: &ten},
+			expected: "`json:\"a\" validate:\"omitempty,min=1,max=10\"`",
+		},
+		{
+			name:     "minLength and maxLength",
+			field:    Field{UnmarshalName: "a", MinLength: &two, MaxLength: &five, Required: true},
+			expected: "`json:\"a\" validate:\"required,min=2,max=5\"`",
+		},
+		{
+			name:     "enum",
+			field:    Field{UnmarshalName: "a", Enum: []interface{}{"red", "dark blue"}},
+			expected: "`json:\"a\" validate:\"omitempty,oneof=red 'dark blue'\"`",
+		},
+	}
+
+	for _, test := range tests {
+		if actual := getFieldTags(g, test.field); actual != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, actual)
+		}
+	}
+
+	generated := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"size": { "type": "number", "minimum": 0.5, "maximum": 100 }
+		}
+	}`, func(g *Generator) {
+		g.EmitValidateTags = true
+	})
+	var buf bytes.Buffer
+	Output(&buf, generated, "main")
+	if !strings.Contains(buf.String(), "`json:\"size\" validate:\"omitempty,min=0.5,max=100\"`") {
+		t.Errorf("expected the schema constraints in the validate tag, got:\n%s", buf.String())
+	}
+}
+
+func TestThatFieldCommentsBeginWithTheFieldName(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"id": { "type": "integer", "description": "the identifier" },
+			"name": { "type": "string" }
+		},
+		"additionalProperties": { "type": "string" }
+	}`
+	tests := []struct {
+		alwaysComment bool
+		expected      []string
+		unexpected    []string
+	}{
+		{
+			alwaysComment: false,
+			expected:      []string{"\n\t// Id the identifier\n\tId int"},
+			unexpected:    []string{"// Name", "// AdditionalProperties"},
+		},
+		{
+			alwaysComment: true,
+			expected: []string{
+				"\n\t// Id the identifier\n\tId int",
+				"\n\t// Name is the \"name\" property.\n\tName string",
+				"\n\t// AdditionalProperties holds the properties which are not defined by the schema.\n\tAdditionalProperties map[string]string",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		g := generateFromJSON(t, schema, func(g *Generator) {
+			g.AlwaysComment = test.alwaysComment
+		})
+		var buf bytes.Buffer
+		Output(&buf, g, "main")
+		for _, e := range test.expected {
+			if !strings.Contains(buf.String(), e) {
+				t.Errorf("with AlwaysComment %v expected the output to contain %q, got:\n%s", test.alwaysComment, e, buf.String())
+			}
+		}
+		for _, u := range test.unexpected {
+			if strings.Contains(buf.String(), u) {
+				t.Errorf("with AlwaysComment %v expected the output not to contain %q, got:\n%s", test.alwaysComment, u, buf.String())
+			}
+		}
+	}
+}
+
+func TestThatCRLFDescriptionsProduceCleanComments(t *testing.T) {
+	tests := []struct {
+		description string
+		expected    string
+	}{
+		{
+			description: "first line\r\nsecond line  \r\n\r\nlast line",
+			expected:    "// Example first line\n// second line\n//\n// last line\n",
+		},
+		{
+			description: "mac\rline endings\t",
+			expected:    "// Example mac\n// line endings\n",
+		},
+		{
+			description: "",
+			expected:    "// Example\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		outputNameAndDescriptionComment("Example", "", test.description, 0, &buf)
+		if buf.String() != test.expected {
+			t.Errorf("for the description %q expected %q, got %q", test.description, test.expected, buf.String())
+		}
+
+		buf.Reset()
+		outputFieldDescriptionComment("Example", test.description, 0, &buf)
+		expected := "\n\t" + strings.Replace(strings.TrimSuffix(test.expected, "\n"), "\n", "\n\t", -1) + "\n"
+		if buf.String() != expected {
+			t.Errorf("for the field description %q expected %q, got %q", test.description, expected, buf.String())
+		}
+	}
+}
+
+func TestThatTheRequiredConstructorTakesTheRequiredFields(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"type": { "type": "string" },
+			"name": { "type": "string" },
+			"count": { "type": "integer" },
+			"comment": { "type": "string" }
+		},
+		"required": [ "type", "name", "count" ]
+	}`, func(g *Generator) {
+		g.GenerateRequiredConstructor = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	if !strings.Contains(buf.String(), "func NewRoot(count int, name string, type_ string) Root {") {
+		t.Errorf("expected the constructor to take the required fields in order, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "\treturn Root{\n\t\tCount: count,\n\t\tName: name,\n\t\tType: type_,\n\t}\n") {
+		t.Errorf("expected the constructor to set the required fields only, got:\n%s", buf.String())
+	}
+}
+
+func TestThatStructCommentsUseTheTitleAndDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		title       string
+		description string
+		expected    string
+	}{
+		{
+			name:        "Address",
+			title:       "Postal address",
+			description: "Where letters are delivered to.",
+			expected:    "// Address Postal address\n//\n// Where letters are delivered to.\n",
+		},
+		{
+			name:     "Address",
+			title:    "Postal address",
+			expected: "// Address Postal address\n",
+		},
+		{
+			name:        "Address",
+			description: "Where letters are delivered to.",
+			expected:    "// Address Where letters are delivered to.\n",
+		},
+		{
+			name:        "Address",
+			title:       "address",
+			description: "Where letters are delivered to.",
+			expected:    "// Address Where letters are delivered to.\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		outputNameAndDescriptionComment(test.name, test.title, test.description, 0, &buf)
+		if buf.String() != test.expected {
+			t.Errorf("for title %q and description %q expected %q, got %q", test.title, test.description, test.expected, buf.String())
+		}
+	}
+}
+
+// returns a schema with n definitions, each with a few properties
+func getLargeSchema(n int) string {
+	definitions := make([]string, n)
+	for i := range definitions {
+		definitions[i] = fmt.Sprintf(`"thing%d": {
+			"type": "object",
+			"properties": {
+				"name": { "type": "string" },
+				"count": { "type": "integer" },
+				"tags": { "type": "array", "items": { "type": "string" } }
+			},
+			"required": [ "name" ]
+		}`, i)
+	}
+	return `{ "definitions": {` + strings.Join(definitions, ",") + `} }`
+}
+
+func TestThatStreamOutputMatchesOutput(t *testing.T) {
+	g := generateFromJSON(t, getLargeSchema(50), nil)
+
+	var buffered, streamed bytes.Buffer
+	Output(&buffered, g, "main")
+	StreamOutput(&streamed, g, "main")
+
+	if buffered.String() != streamed.String() {
+		t.Errorf("expected the streamed output to match the buffered output")
+	}
+}
+
+func BenchmarkOutput(b *testing.B) {
+	g := generateFromJSON(b, getLargeSchema(2000), nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Output(io.Discard, g, "main")
+	}
+}
+
+func BenchmarkStreamOutput(b *testing.B) {
+	g := generateFromJSON(b, getLargeSchema(2000), nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		StreamOutput(io.Discard, g, "main")
+	}
+}
+
+func TestThatTheEmbeddedSchemaIsValidJSON(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string", "description": "the \"name\"" },
+			"child": {
+				"type": "object",
+				"properties": {
+					"id": { "type": "integer" }
+				}
+			}
+		},
+		"additionalProperties": false
+	}`, func(g *Generator) {
+		g.EmbedSchema = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	output := buf.String()
+	for _, expected := range []string{
+		"func (strct Root) FieldNames() []string {\n\treturn []string{\"child\", \"name\"}\n",
+		"func (strct Child) FieldNames() []string {\n\treturn []string{\"id\"}\n",
+		"func (strct Child) JSONSchema() string {\n\treturn \"{\\\"type\\\":\\\"object\\\",\\\"properties\\\":{\\\"id\\\":{\\\"type\\\":\\\"integer\\\"}}}\"\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	// the string literal of the method holds the schema as it was parsed
+	literal := regexp.MustCompile(`func \(strct Root\) JSONSchema\(\) string \{\n\treturn (".*")\n`).FindStringSubmatch(output)
+	if literal == nil {
+		t.Fatalf("expected a JSONSchema method for Root, got:\n%s", output)
+	}
+	schema, err := strconv.Unquote(literal[1])
+	if err != nil || !json.Valid([]byte(schema)) || !strings.Contains(schema, `"additionalProperties":false`) {
+		t.Errorf("expected the embedded schema to be valid JSON, got %s (%v)", literal[1], err)
+	}
+}
+
+func TestThatUUIDFormatsCanUseGoogleUUID(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"id": { "type": "string", "format": "uuid" }
+		}
+	}`, func(g *Generator) {
+		g.UseGoogleUUID = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	if !strings.Contains(buf.String(), "\"github.com/google/uuid\"") || !strings.Contains(buf.String(), "Id uuid.UUID `json:\"id\"`") {
+		t.Errorf("expected the field to be a uuid.UUID, got:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "formatUuid") {
+		t.Errorf("expected no pattern validation for a uuid.UUID, got:\n%s", buf.String())
+	}
+}
+
+func TestThatUnmarshalIntoANilPointerReturnsAnErrorWithGuardUnmarshal(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" }
+		},
+		"required": [ "name" ]
+	}`, func(g *Generator) {
+		g.GuardUnmarshal = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	if !strings.Contains(buf.String(), "var _ json.Unmarshaler = (*Root)(nil)") {
+		t.Errorf("expected a compile time assertion, got:\n%s", buf.String())
+	}
+	expected := "\tif strct == nil {\n\t\treturn errors.New(\"Root.UnmarshalJSON: cannot unmarshal into a nil pointer\")\n\t}\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected UnmarshalJSON to check for a nil pointer, got:\n%s", buf.String())
+	}
+}
+
+func TestThatExtraTagsAreTakenFromTheSchema(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string", "example": "foo" },
+			"count": { "type": "integer", "example": 3 },
+			"other": { "type": "string" }
+		}
+	}`, func(g *Generator) {
+		g.ExtraTags = map[string]string{"example": "example"}
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	actual := buf.String()
+	for _, expected := range []string{
+		"`json:\"name\" example:\"foo\"`",
+		"`json:\"count\" example:\"3\"`",
+		"`json:\"other\"`",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected the tags %s, got:\n%s", expected, actual)
+		}
+	}
+}
+
+func TestThatDeprecatedFieldsAndStructsAreCommented(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"deprecated": true,
+		"properties": {
+			"old": { "type": "string", "deprecated": true },
+			"described": { "type": "string", "description": "is the old name.", "deprecated": true },
+			"current": { "type": "string" }
+		}
+	}`, nil)
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	actual := buf.String()
+	for _, expected := range []string{
+		"// Root\n//\n// Deprecated: Root is marked as deprecated in the schema.\ntype Root struct {",
+		"\t// Deprecated: the \"old\" property is marked as deprecated in the schema.\n\tOld string",
+		"\t// Described is the old name.\n\t//\n\t// Deprecated: the \"described\" property is marked as deprecated in the schema.\n\tDescribed string",
+		"type Root struct {\n\tCurrent string",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected %q, got:\n%s", expected, actual)
+		}
+	}
+}
+
+func TestThatResetZerosScalarsAndEmptiesSlices(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"count": { "type": "integer" },
+			"tags": { "type": "array", "items": { "type": "string" } }
+		},
+		"additionalProperties": { "type": "integer" }
+	}`, func(g *Generator) {
+		g.GenerateReset = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	output := buf.String()
+	// the slices and maps keep their memory, everything else is zeroed
+	for _, expected := range []string{
+		"\tfor k := range strct.AdditionalProperties {\n\t\tdelete(strct.AdditionalProperties, k)\n\t}\n",
+		"\t*strct = Root{\n\t\tAdditionalProperties: strct.AdditionalProperties,\n\t\tTags: strct.Tags[:0],\n\t}\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestThatEmittedCodeIsIndentedWithTabs(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"description": "is the root.",
+		"properties": {
+			"name": { "type": "string", "description": "is the name.", "minLength": 1, "pattern": "^a", "enum": ["a", "ab"] },
+			"count": { "type": "integer", "minimum": 1, "multipleOf": 2 },
+			"ratio": { "type": "number", "multipleOf": 0.5 },
+			"id": { "type": "string", "format": "uuid" },
+			"tags": { "type": "array", "items": { "type": "string" } },
+			"address": { "type": "object", "properties": { "street": { "type": "string" } }, "additionalProperties": false },
+			"labels": { "type": "object", "additionalProperties": { "type": "string" } },
+			"legacy": { "type": "string", "deprecated": true },
+			"code": { "$ref": "#/definitions/code" }
+		},
+		"propertyNames": { "pattern": "^[a-z]+$" },
+		"required": [ "name" ],
+		"definitions": {
+			"code": { "type": "string", "pattern": "^[A-Z]+$", "enum": ["A", "B"] }
+		}
+	}`, func(g *Generator) {
+		g.OptionalAsPointer = true
+		g.GenerateMerge = true
+		g.GenerateMarshalIndent = true
+		g.GenerateTextMarshalers = true
+		g.PreserveUnknownFields = true
+		g.DisallowUnknownFields = true
+		g.AlwaysComment = true
+		g.GenerateRequiredConstructor = true
+		g.EmbedSchema = true
+		g.GuardUnmarshal = true
+		g.GenerateReset = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	for i, line := range strings.Split(buf.String(), "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if strings.Contains(indent, " ") {
+			t.Errorf("line %d is indented with spaces: %q", i+1, line)
+		}
+	}
+}
+
+func TestThatToMapFuncsCallToMapByStructName(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"child": { "$ref": "#/definitions/child" }
+		},
+		"required": [ "name" ],
+		"definitions": {
+			"child": {
+				"type": "object",
+				"properties": { "id": { "type": "integer" } },
+				"required": [ "id" ]
+			}
+		}
+	}`, func(g *Generator) {
+		g.GenerateToMapFuncs = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	output := buf.String()
+	for _, expected := range []string{
+		"var ToMapFuncs = map[string]func(any) map[string]any{\n",
+		"\t\"Child\": func(v any) map[string]any {\n\t\tswitch v := v.(type) {\n\t\tcase *Child:\n\t\t\treturn v.ToMap()\n\t\tcase Child:\n\t\t\treturn v.ToMap()\n\t\t}\n\t\treturn nil\n\t},\n",
+		"\t\"Root\": func(v any) map[string]any {\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestThatIdenticalStructsAreDeduplicated(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"home": { "$ref": "#/definitions/home" },
+			"work": { "$ref": "#/definitions/work" }
+		},
+		"definitions": {
+			"address": { "type": "object", "properties": { "street": { "type": "string" } }, "required": [ "street" ] },
+			"location": { "type": "object", "properties": { "street": { "type": "string" } }, "required": [ "street" ] },
+			"home": { "type": "object", "properties": { "address": { "$ref": "#/definitions/address" } } },
+			"work": { "type": "object", "properties": { "address": { "$ref": "#/definitions/location" } } }
+		}
+	}`, func(g *Generator) {
+		g.DeduplicateStructs = true
+	})
+
+	expected := []string{"Address", "Home", "Root"}
+	if actual := getOrderedStructNames(g.Structs); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the structs %v, got %v", expected, actual)
+	}
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	for _, alias := range []string{"type Location = Address\n", "type Work = Home\n"} {
+		if !strings.Contains(buf.String(), alias) {
+			t.Errorf("expected the alias %q, got:\n%s", alias, buf.String())
+		}
+	}
+}
+
+func TestThatStructsAreDeduplicatedByTheValuesOfTheirConstraints(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"definitions": {
+			"a": { "type": "object", "properties": { "name": { "type": "string", "minLength": 1, "maxLength": 5 }, "size": { "type": "number", "maximum": 10 } } },
+			"b": { "type": "object", "properties": { "name": { "type": "string", "minLength": 1, "maxLength": 5 }, "size": { "type": "number", "maximum": 10 } } },
+			"c": { "type": "object", "properties": { "name": { "type": "string" } }, "minProperties": 1 },
+			"d": { "type": "object", "properties": { "name": { "type": "string" } } }
+		}
+	}`, func(g *Generator) {
+		g.DeduplicateStructs = true
+	})
+
+	expected := []string{"A", "C", "D", "Root"}
+	if actual := getOrderedStructNames(g.Structs); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the structs %v, got %v", expected, actual)
+	}
+	if _, ok := g.Aliases["B"]; !ok {
+		t.Errorf("expected B to be an alias of A, got the aliases %v", g.Aliases)
+	}
+}
+
+func TestThatRequiredFieldsListTheRequiredPropertiesInOrder(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"zeta": { "type": "string" },
+			"Alpha": { "type": "string" },
+			"beta": { "type": "integer" },
+			"optional": { "type": "integer" },
+			"child": { "type": "object", "properties": { "id": { "type": "integer" } } }
+		},
+		"required": [ "zeta", "beta", "Alpha" ]
+	}`, func(g *Generator) {
+		g.GenerateRequiredFields = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	for _, expected := range []string{
+		"var RootRequiredFields = []string{\"Alpha\", \"beta\", \"zeta\"}\n",
+		"var ChildRequiredFields = []string{}\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestThatTheReceivedVariablesOfRequiredPropertiesAreValidIdentifiers(t *testing.T) {
+	// a variable named after the JSON name, e.g. user-idReceived, isn't an identifier
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"user-id": { "type": "integer" },
+			"2fa": { "type": "boolean" },
+			"name": { "type": "string" }
+		},
+		"required": [ "user-id", "2fa", "name" ]
+	}`, nil)
+
+	var buf bytes.Buffer
+	if err := OutputFormatted(&buf, g, "test"); err != nil {
+		t.Fatalf("expected the output to parse, got %v", err)
+	}
+	for _, name := range []string{"receivedUserId", "received_2fa", "receivedName"} {
+		if !strings.Contains(buf.String(), name+" := false") {
+			t.Errorf("expected the variable %s, got:\n%s", name, buf.String())
+		}
+	}
+}
+
+func TestThatBuildTagsPrecedeThePackageClause(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": { "name": { "type": "string" } }
+	}`
+	g := generateFromJSON(t, schema, func(g *Generator) {
+		g.BuildTags = []string{"go1.18", "!never || ignore"}
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	expected := "//go:build go1.18 && (!never || ignore)\n// +build go1.18\n// +build !never ignore\n\n// Code generated by schema-generate. DO NOT EDIT.\n\npackage main\n"
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Errorf("expected the output to start with %q, got:\n%s", expected, buf.String())
+	}
+
+	if err := OutputFormatted(io.Discard, g, "main"); err != nil {
+		t.Errorf("expected the tagged code to parse, got %v", err)
+	}
+
+	root, err := ParseWithSchemaKeyRequired(schema, &url.URL{Scheme: "file", Path: "/test.json"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = New(root)
+	g.BuildTags = []string{"a &&"}
+	if err := g.CreateTypes(); err == nil {
+		t.Error("expected an error for the invalid build tag")
+	}
+}
+
+func TestThatPropertiesWithTheSameCamelCaseKeyAreAnError(t *testing.T) {
+	root, err := ParseWithSchemaKeyRequired(`{
+		"type": "object",
+		"properties": { "user_name": { "type": "string" }, "userName": { "type": "string" } }
+	}`, &url.URL{Scheme: "file", Path: "/test.json"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(root)
+	g.KeyCase = "camel"
+	if err := g.CreateTypes(); err == nil {
+		t.Error("expected an error for properties with the same camel case key")
+	}
+}
+
+func TestThatStructsImplementTheirReaderInterfaces(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"child": { "type": "object", "properties": { "id": { "type": "integer" } } }
+		}
+	}`, func(g *Generator) {
+		g.GenerateReaderInterfaces = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	for _, expected := range []string{
+		"type RootReader interface {\n\tGetChild() *Child\n\tGetName() string\n}",
+		"var _ RootReader = Root{}",
+		"var _ ChildReader = Child{}",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestThatAdditionalPropertiesAreMadeWithACapacityWhenTheCountIsBounded(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"count": { "type": "integer" }
+		},
+		"additionalProperties": { "type": "integer" }
+		%s
+	}`
+	for bounds, expected := range map[string][]string{
+		"": {"strct.AdditionalProperties = make(map[string]int, 0)"},
+		`, "maxProperties": 10`: {
+			"size := len(jsonMap) - 2\n",
+			"if size > 10 {\n",
+			"if size < 0 {\n",
+			"strct.AdditionalProperties = make(map[string]int, size)",
+		},
+	} {
+		g := generateFromJSON(t, fmt.Sprintf(schema, bounds), nil)
+		var buf bytes.Buffer
+		Output(&buf, g, "test")
+		for _, code := range expected {
+			if !strings.Contains(buf.String(), code) {
+				t.Errorf("expected the output for the bounds %q to contain %q, got:\n%s", bounds, code, buf.String())
+			}
+		}
+		if bounds == "" && strings.Contains(buf.String(), "len(jsonMap)") {
+			t.Errorf("expected no capacity hint without bounds, got:\n%s", buf.String())
+		}
+	}
+}
+
+// compares the allocations of unmarshalling a large object of additional properties with the generated code to a map
+// which grows as the properties are added
+func BenchmarkUnmarshalAdditionalProperties(b *testing.B) {
+	g := generateFromJSON(b, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" }
+		},
+		"required": [ "name" ],
+		"additionalProperties": { "type": "integer" },
+		"minProperties": 1,
+		"maxProperties": 5000
+	}`, nil)
 
-	if !reflect.DeepEqual(actual, expected) {
-		t.Errorf("expected %s and actual %s should match in order", strings.Join(expected, ", "), strings.Join(actual, ","))
+	actual := runGenerated(b, g, `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func main() {
+	properties := []string{"\"name\": \"a\""}
+	for i := 0; i < 1000; i++ {
+		properties = append(properties, fmt.Sprintf("\"p%d\": %d", i, i))
 	}
-}
+	data := []byte("{" + strings.Join(properties, ", ") + "}")
 
-func TestThatStructNamesAreOrdered(t *testing.T) {
-	m := map[string]Struct{
-		"c": {},
-		"b": {},
-		"a": {},
+	generated := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var r Root
+			if err := json.Unmarshal(data, &r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	grown := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var jsonMap map[string]json.RawMessage
+			if err := json.Unmarshal(data, &jsonMap); err != nil {
+				b.Fatal(err)
+			}
+			additional := map[string]int{}
+			for k, v := range jsonMap {
+				var value int
+				if k != "name" && json.Unmarshal(v, &value) == nil {
+					additional[k] = value
+				}
+			}
+		}
+	})
+	fmt.Println(generated.AllocsPerOp(), grown.AllocsPerOp())
+}
+`)
+	var generated, grown float64
+	if _, err := fmt.Sscan(actual, &generated, &grown); err != nil {
+		b.Fatalf("unexpected output %q: %v", actual, err)
 	}
+	b.ReportMetric(generated, "generated-allocs/op")
+	b.ReportMetric(grown, "grown-allocs/op")
+}
 
-	actual := getOrderedStructNames(m)
-	expected := []string{"a", "b", "c"}
+func TestThatStructsCanOptOutOfTheGeneratedMarshaler(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"child": {
+				"type": "object",
+				"x-go-no-marshaler": true,
+				"properties": {
+					"id": { "type": "integer", "multipleOf": 2 }
+				},
+				"required": [ "id" ]
+			}
+		},
+		"required": [ "name" ]
+	}`, nil)
 
-	if !reflect.DeepEqual(actual, expected) {
-		t.Errorf("expected %s and actual %s should match in order", strings.Join(expected, ", "), strings.Join(actual, ","))
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	output := buf.String()
+	if !strings.Contains(output, "Id int `json:\"id\"`") {
+		t.Errorf("expected the Child struct to have json tags, got:\n%s", output)
+	}
+	if strings.Contains(output, "func (strct Child) MarshalJSON") || strings.Contains(output, "func (strct *Child) UnmarshalJSON") {
+		t.Errorf("expected Child to have no marshal methods, got:\n%s", output)
+	}
+	if !strings.Contains(output, "func (strct Root) MarshalJSON") {
+		t.Errorf("expected Root to keep its marshal methods, got:\n%s", output)
+	}
+	// only the marshal methods are left out
+	for _, method := range []string{"func (strct *Child) Validate() error", "func (strct *Child) ToMap() map[string]any"} {
+		if !strings.Contains(output, method) {
+			t.Errorf("expected Child to have %q, got:\n%s", method, output)
+		}
 	}
 }
 
-func TestLineAndCharacterFromOffset(t *testing.T) {
+func TestThatHiddenFieldsAreLeftOutOfToMap(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" }
+		},
+		"required": [ "name" ],
+		"additionalProperties": { "type": "integer" }
+	}`
 	tests := []struct {
-		In                []byte
-		Offset            int
-		ExpectedLine      int
-		ExpectedCharacter int
-		ExpectedError     bool
+		includeHidden bool
+		expected      string
 	}{
-		{
-			In:                []byte("Line 1\nLine 2"),
-			Offset:            6,
-			ExpectedLine:      2,
-			ExpectedCharacter: 1,
+		{includeHidden: false, expected: "\tm := make(map[string]any)\n\tm[\"name\"] = strct.Name\n\treturn m\n"},
+		{includeHidden: true, expected: "\tm := make(map[string]any)\n\tm[\"AdditionalProperties\"] = strct.AdditionalProperties\n\tm[\"name\"] = strct.Name\n\treturn m\n"},
+	}
+	for _, test := range tests {
+		g := generateFromJSON(t, schema, func(g *Generator) {
+			g.MapIncludesHidden = test.includeHidden
+		})
+		var buf bytes.Buffer
+		Output(&buf, g, "test")
+		if !strings.Contains(buf.String(), test.expected) {
+			t.Errorf("with MapIncludesHidden %v expected ToMap to contain %q, got:\n%s", test.includeHidden, test.expected, buf.String())
+		}
+	}
+}
+
+func TestThatTheTypeMapperOverridesBuiltInTypes(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"price": { "type": "number" },
+			"prices": { "type": "array", "items": { "type": "number" } },
+			"day": { "type": "string", "format": "date" },
+			"name": { "type": "string" }
+		}
+	}`, func(g *Generator) {
+		g.TypeMapper = func(schemaType, format string) (string, string, bool) {
+			switch {
+			case schemaType == "number":
+				return "decimal.Decimal", "github.com/shopspring/decimal", true
+			case schemaType == "string" && format == "date":
+				return "civil.Date", "cloud.google.com/go/civil", true
+			}
+			return "", "", false
+		}
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	output := buf.String()
+	for _, expected := range []string{
+		"Price decimal.Decimal `json:\"price\"`",
+		"Prices []decimal.Decimal `json:\"prices\"`",
+		"Day civil.Date `json:\"day\"`",
+		"Name string `json:\"name\"`",
+		"\t\"github.com/shopspring/decimal\"\n",
+		"\t\"cloud.google.com/go/civil\"\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestThatTypeMapperPackagesWithTheSameNameAreAliased(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"code": { "type": "string", "format": "code" },
+			"codes": { "type": "array", "items": { "type": "string", "format": "code" } }
+		}
+	}`, func(g *Generator) {
+		g.TypeMapper = func(schemaType, format string) (string, string, bool) {
+			switch {
+			case schemaType == "string" && format == "code":
+				return "v1.Code", "generated/b/v1", true
+			case schemaType == "string":
+				return "v1.Name", "generated/a/v1", true
+			}
+			return "", "", false
+		}
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	output := buf.String()
+	for _, expected := range []string{
+		"\tav1 \"generated/a/v1\"\n",
+		"\t\"generated/b/v1\"\n",
+		"Code v1.Code `json:\"code\"`",
+		"Codes []v1.Code `json:\"codes\"`",
+		"Name av1.Name `json:\"name\"`",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestThatClashingInlineObjectsAreNamedAfterTheirParent(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"definitions": {
+			"person": {
+				"type": "object",
+				"properties": {
+					"address": {
+						"type": "object",
+						"properties": {
+							"street": { "type": "string" }
+						},
+						"required": [ "street" ]
+					}
+				}
+			}
 		},
+		"properties": {
+			"owner": { "$ref": "#/definitions/person" },
+			"address": {
+				"type": "object",
+				"properties": {
+					"city": { "type": "string" }
+				},
+				"required": [ "city" ]
+			}
+		}
+	}`, nil)
+
+	if actual, expected := getOrderedStructNames(g.Structs), []string{"Address", "Person", "Root", "RootAddress"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the structs %v, got %v", expected, actual)
+	}
+}
+
+func TestThatLongDescriptionsAreWrapped(t *testing.T) {
+	tests := []struct {
+		description string
+		expected    string
+	}{
 		{
-			In:                []byte("Line 1\r\nLine 2"),
-			Offset:            7,
-			ExpectedLine:      2,
-			ExpectedCharacter: 1,
+			description: "is short.",
+			expected:    "// Example is short.\n",
 		},
 		{
-			In:                []byte("Line 1\nLine 2"),
-			Offset:            0,
-			ExpectedLine:      1,
-			ExpectedCharacter: 1,
+			description: "is a description which is too long for a single line of a comment.",
+			expected:    "// Example is a description which\n// is too long for a single line of\n// a comment.\n",
 		},
 		{
-			In:                []byte("Line 1\nLine 2"),
-			Offset:            200,
-			ExpectedLine:      0,
-			ExpectedCharacter: 0,
-			ExpectedError:     true,
+			description: "keeps\nexplicit line breaks which are also long enough to wrap\n\n    and indented lines which are longer than the width",
+			expected:    "// Example keeps\n// explicit line breaks which are\n// also long enough to wrap\n//\n//     and indented lines which are longer than the width\n",
 		},
 		{
-			In:                []byte("Line 1\nLine 2"),
-			Offset:            -1,
-			ExpectedLine:      0,
-			ExpectedCharacter: 0,
-			ExpectedError:     true,
+			description: "has https://example.com/a/very/long/url/which/cannot/be/wrapped in it",
+			expected:    "// Example has\n// https://example.com/a/very/long/url/which/cannot/be/wrapped\n// in it\n",
 		},
 	}
 
 	for _, test := range tests {
-		actualLine, actualCharacter, err := lineAndCharacter(test.In, test.Offset)
-		if err != nil && !test.ExpectedError {
-			t.Errorf("Unexpected error for input %s at offset %d: %v", test.In, test.Offset, err)
-			continue
+		var buf bytes.Buffer
+		outputNameAndDescriptionComment("Example", "", test.description, 35, &buf)
+		if buf.String() != test.expected {
+			t.Errorf("for the description %q expected %q, got %q", test.description, test.expected, buf.String())
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			if len(line) > 35 && !strings.Contains(line, "https://") && !strings.HasPrefix(line, "//  ") {
+				t.Errorf("expected %q to be wrapped at 35 columns", line)
+			}
 		}
+	}
+}
 
-		if actualLine != test.ExpectedLine || actualCharacter != test.ExpectedCharacter {
-			t.Errorf("For '%s' at offset %d, expected %d:%d, but got %d:%d", test.In, test.Offset, test.ExpectedLine, test.ExpectedCharacter, actualLine, actualCharacter)
+// returns the code generated from the schema with and without FastUnmarshal, the types of which have a Map and a Fast
+// suffix so that both can be compiled into one program
+func getMapAndFastUnmarshalCode(t testing.TB, schema string) (string, string) {
+	t.Helper()
+	var mapCode, fastCode bytes.Buffer
+	Output(&mapCode, generateFromJSON(t, schema, func(g *Generator) {
+		g.TypeSuffix = "Map"
+	}), "main")
+	Output(&fastCode, generateFromJSON(t, schema, func(g *Generator) {
+		g.TypeSuffix = "Fast"
+		g.FastUnmarshal = true
+	}), "main")
+	return mapCode.String(), fastCode.String()
+}
+
+func TestThatFastUnmarshalMatchesTheMapUnmarshal(t *testing.T) {
+	mapCode, fastCode := getMapAndFastUnmarshalCode(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"count": { "type": "integer" },
+			"tags": { "type": "array", "items": { "type": "string" }, "minItems": 2 },
+			"child": {
+				"type": "object",
+				"properties": {
+					"id": { "type": "integer" }
+				},
+				"required": [ "id" ]
+			}
+		},
+		"required": [ "name" ],
+		"additionalProperties": { "type": "integer" }
+	}`)
+	if !strings.Contains(fastCode, "json.NewDecoder") || strings.Contains(fastCode, "jsonMap") {
+		t.Fatalf("expected the fast unmarshal code to use a decoder only, got:\n%s", fastCode)
+	}
+
+	actual := runFiles(t, map[string]string{
+		"map.go":  mapCode,
+		"fast.go": fastCode,
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, j := range []string{
+		"{\"name\": \"a\", \"count\": 2, \"tags\": [\"x\"], \"child\": {\"id\": 3}, \"extra\": 4}",
+		"{\"name\": null, \"count\": 2}",
+		"{\"count\": 2}",
+		"{\"name\": \"a\", \"child\": {}}",
+		"{\"name\": \"a\", \"extra\": \"b\"}",
+		"{\"name\": 1}",
+		"[]",
+	} {
+		var m RootMap
+		mapErr := json.Unmarshal([]byte(j), &m)
+		var f RootFast
+		fastErr := json.Unmarshal([]byte(j), &f)
+		mapJSON, _ := json.Marshal(m)
+		fastJSON, _ := json.Marshal(f)
+		// what's left in the struct after an error depends on the order the properties are decoded in
+		if (mapErr == nil && string(mapJSON) != string(fastJSON)) || (mapErr == nil) != (fastErr == nil) {
+			fmt.Printf("%s: map %s %v, fast %s %v\n", j, mapJSON, mapErr, fastJSON, fastErr)
+		}
+	}
+	fmt.Println(json.Unmarshal([]byte("{}"), &RootFast{}))
+	var f RootFast
+	fmt.Println(json.Unmarshal([]byte("{\"name\": \"a\", \"tags\": [\"x\"]}"), &f), len(f.Tags), cap(f.Tags))
+}
+`,
+	})
+	expected := "\"name\" is required but was not present\n<nil> 1 2\n"
+	if actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+// compares unmarshalling a struct of 30 fields with and without FastUnmarshal
+func BenchmarkFastUnmarshal(b *testing.B) {
+	properties := []string{}
+	values := []string{}
+	for i := 0; i < 30; i++ {
+		switch i % 3 {
+		case 0:
+			properties = append(properties, fmt.Sprintf(`"s%d": { "type": "string" }`, i))
+			values = append(values, fmt.Sprintf(`\"s%d\": \"value %d\"`, i, i))
+		case 1:
+			properties = append(properties, fmt.Sprintf(`"i%d": { "type": "integer" }`, i))
+			values = append(values, fmt.Sprintf(`\"i%d\": %d`, i, i))
+		case 2:
+			properties = append(properties, fmt.Sprintf(`"b%d": { "type": "boolean" }`, i))
+			values = append(values, fmt.Sprintf(`\"b%d\": true`, i))
+		}
+	}
+	mapCode, fastCode := getMapAndFastUnmarshalCode(b, `{
+		"type": "object",
+		"properties": {`+strings.Join(properties, ",")+`},
+		"required": [ "s0" ]
+	}`)
+
+	actual := runFiles(b, map[string]string{
+		"map.go":  mapCode,
+		"fast.go": fastCode,
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+var data = []byte("{` + strings.Join(values, ", ") + `}")
+
+func main() {
+	mapResult := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var r RootMap
+			if err := json.Unmarshal(data, &r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	fastResult := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var r RootFast
+			if err := json.Unmarshal(data, &r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	fmt.Println(mapResult.NsPerOp(), mapResult.AllocsPerOp(), fastResult.NsPerOp(), fastResult.AllocsPerOp())
+}
+`,
+	})
+	var mapNs, mapAllocs, fastNs, fastAllocs float64
+	if _, err := fmt.Sscan(actual, &mapNs, &mapAllocs, &fastNs, &fastAllocs); err != nil {
+		b.Fatalf("unexpected output %q: %v", actual, err)
+	}
+	b.ReportMetric(mapNs, "map-ns/op")
+	b.ReportMetric(mapAllocs, "map-allocs/op")
+	b.ReportMetric(fastNs, "fast-ns/op")
+	b.ReportMetric(fastAllocs, "fast-allocs/op")
+}
+
+func TestThatYAMLSchemasGenerateTheSameCodeAsJSON(t *testing.T) {
+	jsonSchema := `{"title":"Pet","type":"object","description":"A pet.\nWith <markup> & such.\n","properties":{"name":{"type":"string","minLength":1},"tags":{"type":"array","items":{"type":"string"}},"owner":{"$ref":"#/definitions/owner"}},"required":["name"],"definitions":{"owner":{"type":"object","properties":{"id":{"type":"integer","multipleOf":2}}}}}`
+	yamlSchema := `# a pet
+title: Pet
+type: object
+description: |
+  A pet.
+  With <markup> & such.
+properties:
+  name:
+    type: string
+    minLength: 1
+  tags:
+    type: array
+    items: { type: string }
+  owner:
+    $ref: '#/definitions/owner'
+required: [ name ]
+definitions:
+  owner:
+    type: object
+    properties:
+      id:
+        type: integer
+        multipleOf: 2
+`
+	uri, _ := url.Parse("file:///test.json")
+	outputs := []string{}
+	for _, parse := range []func() (*Schema, error){
+		func() (*Schema, error) { return ParseWithSchemaKeyRequired(jsonSchema, uri, false) },
+		func() (*Schema, error) { return ParseYAML(yamlSchema, uri, false) },
+	} {
+		root, err := parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := New(root)
+		g.EmbedSchema = true
+		if err := g.CreateTypes(); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		Output(&buf, g, "test")
+		outputs = append(outputs, buf.String())
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("expected the same code from JSON and YAML, got:\n%s\nand:\n%s", outputs[0], outputs[1])
+	}
+}
+
+func TestThatDiscriminatorsAreSetByTheVariantConstructors(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"definitions": {
+			"cat": {
+				"type": "object",
+				"properties": {
+					"kind": { "const": "cat" },
+					"lives": { "type": "integer" }
+				},
+				"required": [ "kind", "lives" ]
+			},
+			"dog": {
+				"type": "object",
+				"properties": {
+					"kind": { "type": "string", "const": "dog" },
+					"name": { "type": "string" }
+				}
+			}
+		},
+		"oneOf": [ { "$ref": "#/definitions/cat" }, { "$ref": "#/definitions/dog" } ]
+	}`, func(g *Generator) {
+		g.GenerateDiscriminators = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	for _, expected := range []string{
+		"func NewCat(lives int) Cat {\n\treturn Cat{\n\t\tKind: KindCat,\n\t\tLives: lives,\n\t}\n}\n",
+		"func NewDog() Dog {\n\treturn Dog{\n\t\tKind: KindDog,\n\t}\n}\n",
+		"const (\n\tKindCat Kind = \"cat\"\n\tKindDog Kind = \"dog\"\n)\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestThatTheBenchmarkFileCompilesAndRuns(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"count": { "type": "integer" }
+		},
+		"required": [ "name" ],
+		"examples": [ { "name": "a", "count": 1 } ]
+	}`, func(g *Generator) {
+		g.GenerateBenchmarks = true
+	})
+
+	var code, benchmarks bytes.Buffer
+	Output(&code, g, "main")
+	OutputBenchmarks(&benchmarks, g, "main")
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                  "module generated\n\ngo 1.18\n",
+		"generated.go":            code.String(),
+		"generated_bench_test.go": benchmarks.String(),
+		"main.go":                 "package main\n\nfunc main() {}\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", ".", "-benchtime", "1x")
+	cmd.Dir = dir
+	// the generated code has no dependencies, so no flags of the environment are needed
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOTOOLCHAIN=local")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to run the benchmarks: %v\n%s\nbenchmarks:\n%s", err, out, benchmarks.String())
+	}
+	for _, name := range []string{"BenchmarkRootMarshalJSON", "BenchmarkRootUnmarshalJSON"} {
+		if !strings.Contains(string(out), name) {
+			t.Errorf("expected %s to run, got:\n%s", name, out)
+		}
+	}
+}
+
+func TestThatXMLTagsUseThePropertyNames(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"id": { "type": "string", "x-xml-attr": true },
+			"display_name": { "type": "string", "omitEmpty": true }
+		},
+		"additionalProperties": true
+	}`, func(g *Generator) {
+		g.EmitXMLTags = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	actual := buf.String()
+	for _, expected := range []string{
+		"`json:\"id\" xml:\"id,attr\"`",
+		"`json:\"display_name,omitempty\" xml:\"display_name,omitempty\"`",
+		"`json:\"-\" xml:\"-\"`",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected the tags %s, got:\n%s", expected, actual)
+		}
+	}
+}
+
+func TestThatTheReceiverNameIsUsedByAllTheMethods(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"count": { "type": "integer", "multipleOf": 2 },
+			"tags": { "type": "array", "items": { "type": "string" } }
+		},
+		"required": [ "name" ],
+		"additionalProperties": { "type": "integer" }
+	}`
+	g := generateFromJSON(t, schema, func(g *Generator) {
+		g.ReceiverName = "rt"
+		g.GenerateMerge = true
+		g.GenerateDiff = true
+		g.GenerateReset = true
+		g.GenerateMarshalIndent = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	code := buf.String()
+	if strings.Contains(code, "strct") {
+		t.Errorf("expected no strct receivers, got:\n%s", code)
+	}
+	for _, expected := range []string{
+		"func (rt Root) MarshalJSON()",
+		"func (rt *Root) UnmarshalJSON(",
+		"func (rt *Root) ToMap()",
+		"func (rt *Root) Validate()",
+		"func (rt *Root) Merge(",
+		"func (rt *Root) Reset()",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("expected %s, got:\n%s", expected, code)
+		}
+	}
+
+	uri, _ := url.Parse("file:///test.json")
+	root, err := ParseWithSchemaKeyRequired(schema, uri, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = New(root)
+	g.ReceiverName = "lines"
+	if err := g.CreateTypes(); err == nil {
+		t.Errorf("expected an error for a receiver name which the methods use")
+	}
+}
+
+func TestThatTheMarshalJSONOfDefinedFieldsDoesNotUseFmt(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"count": { "type": "integer" },
+			"raw": {},
+			"tags": { "type": "array", "items": { "type": "string" } }
+		},
+		"required": [ "name" ]
+	}`, func(g *Generator) {
+		g.EmptySliceNotNull = true
+	})
+
+	// only the MarshalJSON method, the errors of UnmarshalJSON name the field with fmt.Errorf
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	output := buf.String()
+	start := strings.Index(output, "func (strct Root) MarshalJSON() ([]byte, error) {\n")
+	if start < 0 {
+		t.Fatalf("expected a MarshalJSON method, got:\n%s", output)
+	}
+	method := output[start : start+strings.Index(output[start:], "\n}\n")]
+	if strings.Contains(method, "fmt.") {
+		t.Errorf("expected MarshalJSON not to use fmt, got:\n%s", method)
+	}
+}
+
+// the generated marshal code as it was before the lines of the defined fields were concatenated
+var concatenatedLinePattern = regexp.MustCompile(`lines = append\(lines, "(\\"[^\\]*\\": )"\+string\(tmp\)\)`)
+
+func BenchmarkMarshalConcatenation(b *testing.B) {
+	properties := []string{}
+	for i := 0; i < 30; i++ {
+		properties = append(properties, fmt.Sprintf(`"s%d": { "type": "string" }`, i))
+	}
+	schema := `{
+		"type": "object",
+		"properties": {` + strings.Join(properties, ",") + `},
+		"required": [ "s0" ]
+	}`
+	var concatCode, sprintfCode bytes.Buffer
+	Output(&concatCode, generateFromJSON(b, schema, func(g *Generator) {
+		g.TypeSuffix = "Concat"
+	}), "main")
+	Output(&sprintfCode, generateFromJSON(b, schema, func(g *Generator) {
+		g.TypeSuffix = "Sprintf"
+	}), "main")
+
+	actual := runFiles(b, map[string]string{
+		"concat.go":  concatCode.String(),
+		"sprintf.go": concatenatedLinePattern.ReplaceAllString(sprintfCode.String(), `lines = append(lines, fmt.Sprintf("${1}%s", tmp))`),
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+var data = []byte("{\"s0\": \"a\", \"s1\": \"b\", \"s2\": \"c\"}")
+
+func main() {
+	var concat RootConcat
+	var sprintf RootSprintf
+	if err := json.Unmarshal(data, &concat); err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, &sprintf); err != nil {
+		panic(err)
+	}
+	concatResult := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := concat.MarshalJSON(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	sprintfResult := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := sprintf.MarshalJSON(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	fmt.Println(concatResult.NsPerOp(), concatResult.AllocsPerOp(), sprintfResult.NsPerOp(), sprintfResult.AllocsPerOp())
+}
+`,
+	})
+	var concatNs, concatAllocs, sprintfNs, sprintfAllocs float64
+	if _, err := fmt.Sscan(actual, &concatNs, &concatAllocs, &sprintfNs, &sprintfAllocs); err != nil {
+		b.Fatalf("unexpected output %q: %v", actual, err)
+	}
+	b.ReportMetric(concatNs, "concat-ns/op")
+	b.ReportMetric(concatAllocs, "concat-allocs/op")
+	b.ReportMetric(sprintfNs, "sprintf-ns/op")
+	b.ReportMetric(sprintfAllocs, "sprintf-allocs/op")
+}
+
+func TestThatPostProcessCanAddMethods(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" }
+		}
+	}`, func(g *Generator) {
+		g.PostProcess = func(file *ast.File) error {
+			// func (r Root) Hello() string { return "hello " + r.Name }
+			file.Decls = append(file.Decls, &ast.FuncDecl{
+				Recv: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{ast.NewIdent("r")}, Type: ast.NewIdent("Root")}}},
+				Name: ast.NewIdent("Hello"),
+				Type: &ast.FuncType{
+					Params:  &ast.FieldList{},
+					Results: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("string")}}},
+				},
+				Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{&ast.BinaryExpr{
+					X:  &ast.BasicLit{Kind: token.STRING, Value: `"hello "`},
+					Op: token.ADD,
+					Y:  &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("Name")},
+				}}}}},
+			})
+			return nil
+		}
+	})
+
+	var buf bytes.Buffer
+	if err := OutputFormatted(&buf, g, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "func (r Root) Hello() string") {
+		t.Errorf("expected the added method, got:\n%s", buf.String())
+	}
+
+	g.PostProcess = func(file *ast.File) error {
+		return errors.New("failed")
+	}
+	if err := OutputFormatted(io.Discard, g, "main"); err == nil || err.Error() != "failed" {
+		t.Errorf("expected the error of PostProcess, got %v", err)
+	}
+}
+
+func TestThatTheFieldMapHasAnEntryForEachProperty(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"display_name": { "type": "string" },
+			"id": { "type": "integer" },
+			"child": { "type": "object", "properties": { "x-y": { "type": "integer" } } }
+		},
+		"additionalProperties": { "type": "string" }
+	}`, func(g *Generator) {
+		g.GenerateFieldMap = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	for _, expected := range []string{
+		"var RootFieldMap = map[string]string{\n\t\"child\": \"Child\",\n\t\"display_name\": \"DisplayName\",\n\t\"id\": \"Id\",\n}\n",
+		"var ChildFieldMap = map[string]string{\n\t\"x-y\": \"XY\",\n}\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestThatTheToolNameIsInTheGeneratedCodeComment(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": { "name": { "type": "string" } }
+	}`
+	g := generateFromJSON(t, schema, func(g *Generator) {
+		g.ToolName = "my-wrapper v1.2"
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	// the regular expression in https://go.dev/s/generatedcode
+	generated := regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$`)
+	line := generated.FindString(buf.String())
+	if line != "// Code generated by my-wrapper v1.2. DO NOT EDIT." {
+		t.Errorf("expected the comment to name the tool, got %q in:\n%s", line, buf.String())
+	}
+
+	root, err := ParseWithSchemaKeyRequired(schema, &url.URL{Scheme: "file", Path: "/test.json"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = New(root)
+	g.ToolName = "a\nb"
+	if err := g.CreateTypes(); err == nil {
+		t.Error("expected an error for the tool name with a line break")
+	}
+}
+
+func BenchmarkMarshalPooledBuffers(b *testing.B) {
+	properties := []string{}
+	for i := 0; i < 30; i++ {
+		properties = append(properties, fmt.Sprintf(`"s%d": { "type": "string" }`, i))
+	}
+	schema := `{
+		"type": "object",
+		"properties": {` + strings.Join(properties, ",") + `},
+		"required": [ "s0" ]
+	}`
+	var pooledCode, plainCode bytes.Buffer
+	Output(&pooledCode, generateFromJSON(b, schema, func(g *Generator) {
+		g.TypeSuffix = "Pooled"
+		g.PooledBuffers = true
+	}), "main")
+	Output(&plainCode, generateFromJSON(b, schema, func(g *Generator) {
+		g.TypeSuffix = "Plain"
+	}), "main")
+
+	actual := runFiles(b, map[string]string{
+		"pooled.go": pooledCode.String(),
+		"plain.go":  plainCode.String(),
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+var data = []byte("{\"s0\": \"a\", \"s1\": \"b\", \"s2\": \"c\"}")
+
+func main() {
+	var pooled RootPooled
+	var plain RootPlain
+	if err := json.Unmarshal(data, &pooled); err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		panic(err)
+	}
+	pooledResult := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := pooled.MarshalJSON(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	plainResult := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := plain.MarshalJSON(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	fmt.Println(pooledResult.NsPerOp(), pooledResult.AllocsPerOp(), pooledResult.AllocedBytesPerOp(),
+		plainResult.NsPerOp(), plainResult.AllocsPerOp(), plainResult.AllocedBytesPerOp())
+}
+`,
+	})
+	var pooledNs, pooledAllocs, pooledBytes, plainNs, plainAllocs, plainBytes float64
+	if _, err := fmt.Sscan(actual, &pooledNs, &pooledAllocs, &pooledBytes, &plainNs, &plainAllocs, &plainBytes); err != nil {
+		b.Fatalf("unexpected output %q: %v", actual, err)
+	}
+	b.ReportMetric(pooledNs, "pooled-ns/op")
+	b.ReportMetric(pooledAllocs, "pooled-allocs/op")
+	b.ReportMetric(pooledBytes, "pooled-B/op")
+	b.ReportMetric(plainNs, "plain-ns/op")
+	b.ReportMetric(plainAllocs, "plain-allocs/op")
+	b.ReportMetric(plainBytes, "plain-B/op")
+}
+
+func TestThatMarshalOnlySkipsTheUnmarshalMethods(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" },
+			"child": {
+				"type": "object",
+				"properties": { "size": { "type": "integer" } },
+				"required": [ "size" ]
+			}
+		},
+		"required": [ "name" ]
+	}`, func(g *Generator) {
+		g.MarshalOnly = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "main")
+	if strings.Contains(buf.String(), "UnmarshalJSON") {
+		t.Errorf("expected no UnmarshalJSON methods, got:\n%s", buf.String())
+	}
+	for _, expected := range []string{
+		"func (strct Root) MarshalJSON() ([]byte, error) {\n",
+		"func (strct Child) MarshalJSON() ([]byte, error) {\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestThatSchemaCommentsAreOnlyIncludedWhenEnabled(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"$comment": "kept in sync with the billing API",
+		"properties": {
+			"name": { "type": "string", "description": "the name of the account", "$comment": "max 64 in practice" },
+			"size": { "type": "integer", "$comment": "bytes" }
+		}
+	}`
+
+	var buf bytes.Buffer
+	Output(&buf, generateFromJSON(t, schema, nil), "main")
+	if strings.Contains(buf.String(), "$comment") {
+		t.Errorf("expected no $comment lines without IncludeSchemaComments, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	Output(&buf, generateFromJSON(t, schema, func(g *Generator) {
+		g.IncludeSchemaComments = true
+	}), "main")
+	for _, expected := range []string{
+		"// Root\n//\n// $comment: kept in sync with the billing API\ntype Root struct {\n",
+		"\t// Name the name of the account\n\t//\n\t// $comment: max 64 in practice\n\tName string",
+		"\n\n\t// $comment: bytes\n\tSize int",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, buf.String())
 		}
 	}
 }
+
+func TestThatOptionalFieldsDoNotImportErrors(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"child": {
+				"type": "object",
+				"properties": { "name": { "type": "string" } },
+				"additionalProperties": { "type": "string" }
+			},
+			"count": { "type": "integer" },
+			"name": { "type": "string" }
+		},
+		"additionalProperties": { "type": "integer" }
+	}`, nil)
+	if !g.Structs["Root"].GenerateCode {
+		t.Fatal("expected the struct to have generated methods")
+	}
+
+	imports := map[string]bool{}
+	var buf bytes.Buffer
+	emitMarshalCode(&buf, g, g.Structs["Root"], imports)
+	emitUnmarshalCode(&buf, g, g.Structs["Root"], imports)
+	if imports["errors"] {
+		t.Errorf("expected the methods not to import errors, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	Output(&buf, g, "main")
+	if strings.Contains(buf.String(), `"errors"`) {
+		t.Errorf("expected the output not to import errors, got:\n%s", buf.String())
+	}
+}
+
+func TestThatAdditionalKeysAreSorted(t *testing.T) {
+	g := generateFromJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string" }
+		},
+		"additionalProperties": { "type": "integer" }
+	}`, func(g *Generator) {
+		g.GenerateAdditionalKeys = true
+	})
+
+	var buf bytes.Buffer
+	Output(&buf, g, "test")
+	expected := "\tfor k := range strct.AdditionalProperties {\n\t\tkeys = append(keys, k)\n\t}\n\tsort.Strings(keys)\n\treturn keys\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected AdditionalKeys to sort the keys, got:\n%s", buf.String())
+	}
+}
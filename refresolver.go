@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path"
 	"strings"
 )
 
@@ -61,12 +63,42 @@ func (r *RefResolver) GetSchemaByReference(schema *Schema) (*Schema, error) {
 	}
 	resolvedPath := u.ResolveReference(ref)
 	path, ok := r.pathToSchema[resolvedPath.String()]
+	if !ok && resolvedPath.Scheme == "file" {
+		// the reference may point into a local file which wasn't one of the inputs, load it and try again
+		if err := r.loadFile(*resolvedPath); err != nil {
+			return nil, err
+		}
+		path, ok = r.pathToSchema[resolvedPath.String()]
+	}
 	if !ok {
 		return nil, errors.New("refresolver.GetSchemaByReference: reference not found: " + schema.Reference)
 	}
 	return path, nil
 }
 
+// loadFile reads the schema document a file URI refers to and maps its paths, unless it is already known.
+func (r *RefResolver) loadFile(uri url.URL) error {
+	uri.Fragment = ""
+	if _, ok := r.pathToSchema[uri.String()]; ok {
+		return nil
+	}
+	b, err := os.ReadFile(uri.Path)
+	if err != nil {
+		return errors.New("refresolver.loadFile: failed to read the referenced file with error " + err.Error())
+	}
+	if ext := path.Ext(uri.Path); ext == ".yaml" || ext == ".yml" {
+		if b, err = yamlToJSON(b); err != nil {
+			return fmt.Errorf("refresolver.loadFile: failed to parse the referenced YAML file %s with error %v", uri.Path, err)
+		}
+	}
+	schema, err := ParseWithSchemaKeyRequired(string(b), &uri, false)
+	if err != nil {
+		return fmt.Errorf("refresolver.loadFile: failed to parse the referenced file %s with error %v", uri.Path, err)
+	}
+	r.schemas = append(r.schemas, schema)
+	return r.mapPaths(schema)
+}
+
 func (r *RefResolver) mapPaths(schema *Schema) error {
 	rootURI := &url.URL{}
 	id := schema.ID()
@@ -126,6 +158,12 @@ func (r *RefResolver) updateURIs(schema *Schema, baseURI url.URL, checkCurrentID
 		}
 	}
 	for k, subSchema := range schema.Definitions {
+		// the definitions of $defs can be referred to with either keyword
+		defsURI := baseURI
+		defsURI.Fragment += "/$defs/" + k
+		if err := r.InsertURI(defsURI.String(), subSchema); err != nil {
+			return err
+		}
 		newBaseURI := baseURI
 		newBaseURI.Fragment += "/definitions/" + k
 		if err := r.InsertURI(newBaseURI.String(), subSchema); err != nil {
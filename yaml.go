@@ -0,0 +1,107 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseYAML parses a JSON schema written in YAML from a string. The YAML is converted to JSON with the keys in the
+// same order, so the types generated from it are the same as from the equivalent JSON schema.
+//
+// Anchors and aliases are resolved, only the first document of a stream is used.
+func ParseYAML(schema string, uri *url.URL, schemaKeyRequired bool) (*Schema, error) {
+	b, err := yamlToJSON([]byte(schema))
+	if err != nil {
+		return nil, err
+	}
+	return ParseWithSchemaKeyRequired(string(b), uri, schemaKeyRequired)
+}
+
+// a %YAML 1.2 directive, which the decoder rejects because it only knows the version 1.1 of the directive
+var yaml12Directive = regexp.MustCompile(`(?m)^%YAML 1\.2([ \t]|$)`)
+
+// converts the YAML document to JSON
+func yamlToJSON(document []byte) ([]byte, error) {
+	document = yaml12Directive.ReplaceAll(document, []byte("%YAML 1.1$1"))
+	var root yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(document)).Decode(&root); err != nil {
+		if errors.Is(err, io.EOF) {
+			return []byte("null"), nil
+		}
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeYAMLNode(&buf, &root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writes the node as JSON, mappings become objects with the keys in the same order
+func writeYAMLNode(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeYAMLNode(buf, node.Content[0])
+	case yaml.AliasNode:
+		return writeYAMLNode(buf, node.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONValue(buf, node.Content[i].Value); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeYAMLNode(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeYAMLNode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	if err := writeJSONValue(buf, value); err != nil {
+		return fmt.Errorf("line %d: the value %q can't be converted to JSON: %v", node.Line, node.Value, err)
+	}
+	return nil
+}
+
+// writes the value as JSON without escaping HTML characters, so descriptions keep them as they are
+func writeJSONValue(buf *bytes.Buffer, value interface{}) error {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	return nil
+}